@@ -0,0 +1,104 @@
+package main
+
+import (
+	"image"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// updateAges advances the age of every cell after a generation, given the
+// states before it. A cell that kept its live state grows one generation
+// older; cells that were born, changed state or died start again at zero.
+// With MaxAge set, cells reaching that age die regardless of the rule.
+func (w *World) updateAges(prev []State) {
+	if len(w.ages) != len(w.area) {
+		w.ages = make([]uint16, len(w.area))
+	}
+	for i, s := range w.area {
+		switch {
+		case s == Dead || s != prev[i]:
+			w.ages[i] = 0
+			continue
+		case w.ages[i] < math.MaxUint16:
+			w.ages[i]++
+		}
+		if w.MaxAge > 0 && int(w.ages[i]) >= w.MaxAge {
+			w.set(i, Dead)
+			w.ages[i] = 0
+		}
+	}
+}
+
+// Age returns the number of generations the cell at (x, y) has kept its
+// current live state. Dead cells and cells outside the world have age 0.
+func (w *World) Age(x, y int) int {
+	if x < 0 || y < 0 || x >= w.width || y >= w.height || w.ages == nil {
+		return 0
+	}
+	return int(w.ages[y*w.width+x])
+}
+
+// ageFade is the age in generations at which cells drawn by DrawAgePixels
+// reach their dimmest, and ageFloor how much of their color is left then,
+// out of 256.
+const (
+	ageFade  = 32
+	ageFloor = 64
+)
+
+// DrawAgePixels is like DrawPixels, but fades every live cell from its
+// color in p towards the background as it ages: newborn cells are drawn at
+// full strength, cells ageFade generations old or older at ageFloor/256 of
+// it. Still lifes and oscillator rotors that keep their state thus fade out,
+// while the cells of moving patterns stay bright. Only square grids without
+// zones are drawn this way.
+func (w *World) DrawAgePixels(img *image.RGBA, p palette) bool {
+	if w.Grid() != SquareGrid || w.zones != nil {
+		return false
+	}
+	if len(w.ages) != len(w.area) {
+		return w.DrawPixels(img, p)
+	}
+	colors := p.rgbaTable()
+	bg := colors[Dead]
+	b := w.bounds.Intersect(img.Rect)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := w.area[y*w.width : (y+1)*w.width]
+		ages := w.ages[y*w.width : (y+1)*w.width]
+		pix := img.Pix[img.PixOffset(0, y):]
+		for x := b.Min.X; x < b.Max.X; x++ {
+			s := row[x]
+			if s == Dead {
+				continue
+			}
+			f := uint32(256)
+			if a := uint32(ages[x]); a >= ageFade {
+				f = ageFloor
+			} else {
+				f -= (256 - ageFloor) * a / ageFade
+			}
+			c := colors[s]
+			pix[4*x] = fade(c.R, bg.R, f)
+			pix[4*x+1] = fade(c.G, bg.G, f)
+			pix[4*x+2] = fade(c.B, bg.B, f)
+			pix[4*x+3] = fade(c.A, bg.A, f)
+		}
+	}
+	w.drawFlagPixels(img)
+	return true
+}
+
+// fade mixes f/256 of the channel c with the rest of the channel bg.
+func fade(c, bg uint8, f uint32) uint8 {
+	return uint8((uint32(c)*f + uint32(bg)*(256-f)) >> 8)
+}
+
+// handleAgeColors toggles drawing worlds by age with the A key.
+func (r *Renderer) handleAgeColors() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyA) {
+		r.ageColors = !r.ageColors
+		r.redraw()
+	}
+}
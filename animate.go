@@ -0,0 +1,93 @@
+package main
+
+import (
+	"image"
+	"math"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// animDuration is how long births and deaths take to play out, a little
+// less than a tick of the world update loop so that each finishes before
+// the next generation arrives.
+const animDuration = 80 * time.Millisecond
+
+// animation is the transition from the previous generation of a world to
+// the current one: newborn cells grow and fade in, and cells that died
+// fade out, instead of switching the moment the generation changes.
+type animation struct {
+	// changes are the births and deaths of the last generation, and at the
+	// time it was computed.
+	changes []Change
+	at      time.Time
+	// pixel is a white pixel, scaled and tinted into every cell drawn.
+	pixel *ebiten.Image
+}
+
+// animate makes the renderer animate the generations of w, by subscribing
+// to its changes. Like Subscribe, it must be called on the goroutine that
+// runs w, or before it starts.
+func (r *Renderer) animate(w *World) {
+	w.Subscribe(func(changes []Change) {
+		r.anim.changes = append(r.anim.changes[:0], changes...)
+		r.anim.at = time.Now()
+	})
+	r.animating = true
+}
+
+// drawAnimation draws the births and deaths of the last generation of w
+// over screen, as far as they have got. The frame already shows the new
+// generation, so newborn cells are first covered with the background and
+// then drawn shrunk and faded by how far along the animation is, and dead
+// cells are drawn fading out. Worlds drawn as sprites aren't animated.
+func (r *Renderer) drawAnimation(screen *ebiten.Image, w *World, p palette) {
+	a := &r.anim
+	t := float64(time.Since(a.at)) / float64(animDuration)
+	if !r.animating || r.frameSprites || w.Grid() != SquareGrid || t >= 1 || len(a.changes) == 0 {
+		return
+	}
+	if a.pixel == nil {
+		a.pixel = ebiten.NewImage(1, 1)
+		a.pixel.Fill(image.White)
+	}
+	x0, y0 := r.camera.ScreenToWorld(0, 0)
+	x1, y1 := r.camera.ScreenToWorld(float64(r.screen.X), float64(r.screen.Y))
+	visible := image.Rect(int(math.Floor(x0)), int(math.Floor(y0)), int(math.Ceil(x1)), int(math.Ceil(y1)))
+
+	// draw draws the cell at (x, y) scaled by size around its centre, in
+	// c scaled by alpha.
+	op := &ebiten.DrawImageOptions{}
+	draw := func(x, y int, size float64, s State, alpha float64) {
+		op.GeoM.Reset()
+		op.GeoM.Scale(size, size)
+		op.GeoM.Translate(float64(x)+(1-size)/2, float64(y)+(1-size)/2)
+		op.GeoM.Concat(r.camera.GeoM())
+		op.ColorM.Reset()
+		cr, cg, cb, ca := rgbaFloats(p.color(s))
+		if ca > 0 {
+			// ColorM works with straight alpha.
+			cr, cg, cb = cr/ca, cg/ca, cb/ca
+		}
+		op.ColorM.Scale(cr, cg, cb, ca*alpha)
+		screen.DrawImage(a.pixel, op)
+	}
+	// Each pass uses a single composite mode, so that Ebiten can batch it.
+	op.CompositeMode = ebiten.CompositeModeCopy
+	for _, c := range a.changes {
+		if c.Born && image.Pt(c.X, c.Y).In(visible) {
+			draw(c.X, c.Y, 1, Dead, 1)
+		}
+	}
+	op.CompositeMode = ebiten.CompositeModeSourceOver
+	for _, c := range a.changes {
+		if !image.Pt(c.X, c.Y).In(visible) {
+			continue
+		}
+		if c.Born {
+			draw(c.X, c.Y, t, w.Get(c.X, c.Y), t)
+		} else {
+			draw(c.X, c.Y, 1, Alive, 1-t)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+	"time"
+)
+
+// pngSignature starts every PNG file.
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// apngFrame is a frame of an APNG clip: its compressed image data, as in
+// the IDAT chunks of a PNG, and how long it is shown in milliseconds.
+type apngFrame struct {
+	data  []byte
+	delay uint16
+}
+
+// apngEncoder is the clipEncoder of animated PNGs. Every frame is
+// compressed by image/png as it is added, and write takes the encoded
+// data back out of its chunks to lay out the APNG: the IHDR of the first
+// frame, an acTL chunk with the number of frames, and for every frame an
+// fcTL chunk followed by its data, in IDAT chunks for the first frame so
+// that viewers without APNG support show that, and in fdAT chunks after.
+type apngEncoder struct {
+	ihdr   []byte
+	frames []apngFrame
+	buf    bytes.Buffer
+}
+
+// add implements clipEncoder.
+func (e *apngEncoder) add(img *image.RGBA, delay time.Duration) error {
+	e.buf.Reset()
+	if err := png.Encode(&e.buf, img); err != nil {
+		return err
+	}
+	f := apngFrame{delay: uint16(min(int(delay/time.Millisecond), 0xffff))}
+	b := e.buf.Bytes()[len(pngSignature):]
+	for len(b) >= 12 {
+		n := binary.BigEndian.Uint32(b)
+		typ, data := string(b[4:8]), b[8:8+n]
+		switch typ {
+		case "IHDR":
+			if e.ihdr == nil {
+				e.ihdr = append([]byte(nil), data...)
+			} else if !bytes.Equal(e.ihdr, data) {
+				// The color type image/png picks depends on whether
+				// the frame is opaque, which captured frames all are.
+				return errors.New("apng: frames differ in size or color type")
+			}
+		case "IDAT":
+			f.data = append(f.data, data...)
+		}
+		b = b[12+n:]
+	}
+	e.frames = append(e.frames, f)
+	return nil
+}
+
+// write implements clipEncoder.
+func (e *apngEncoder) write(w io.Writer) error {
+	if len(e.frames) == 0 {
+		return errors.New("apng: no frames")
+	}
+	if _, err := io.WriteString(w, pngSignature); err != nil {
+		return err
+	}
+	cw := &chunkWriter{w: w}
+	cw.chunk("IHDR", e.ihdr)
+
+	var actl [8]byte
+	binary.BigEndian.PutUint32(actl[0:], uint32(len(e.frames)))
+	// The second field is the number of plays, 0 for looping forever.
+	cw.chunk("acTL", actl[:])
+
+	width, height := e.ihdr[0:4], e.ihdr[4:8]
+	seq := uint32(0)
+	for i, f := range e.frames {
+		var fctl [26]byte
+		binary.BigEndian.PutUint32(fctl[0:], seq)
+		copy(fctl[4:], width)
+		copy(fctl[8:], height)
+		// The frame covers the whole canvas at offset 0, 0.
+		binary.BigEndian.PutUint16(fctl[20:], f.delay)
+		binary.BigEndian.PutUint16(fctl[22:], 1000)
+		// Dispose and blend operations 0: leave the frame in place and
+		// replace the canvas with it.
+		cw.chunk("fcTL", fctl[:])
+		seq++
+		if i == 0 {
+			cw.chunk("IDAT", f.data)
+			continue
+		}
+		fdat := make([]byte, 4+len(f.data))
+		binary.BigEndian.PutUint32(fdat, seq)
+		copy(fdat[4:], f.data)
+		cw.chunk("fdAT", fdat)
+		seq++
+	}
+	cw.chunk("IEND", nil)
+	return cw.err
+}
+
+// chunkWriter writes PNG chunks to w, keeping the first error.
+type chunkWriter struct {
+	w   io.Writer
+	err error
+}
+
+// chunk writes a chunk of the given type and data, with its length and
+// CRC.
+func (cw *chunkWriter) chunk(typ string, data []byte) {
+	if cw.err != nil {
+		return
+	}
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(data)))
+	copy(header[4:], typ)
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	var footer [4]byte
+	binary.BigEndian.PutUint32(footer[:], crc.Sum32())
+	for _, b := range [][]byte{header[:], data, footer[:]} {
+		if _, err := cw.w.Write(b); err != nil {
+			cw.err = err
+			return
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// benchSamples is roughly how many times a benchmark samples the memory in
+// use, which briefly stops the world.
+const benchSamples = 100
+
+// benchResult is the outcome of a headless benchmark.
+type benchResult struct {
+	ticks       int
+	generations int64
+	elapsed     time.Duration
+	allocs      uint64
+	allocBytes  uint64
+	peakHeap    uint64
+}
+
+// runBenchmark advances sim by ticks ticks as fast as it goes, without a
+// window, measuring time and memory. Only builds made with -tags
+// ebitencbackend run it without a display, see headless.
+func runBenchmark(sim Simulation, ticks int) benchResult {
+	perTick := int64(1)
+	if h, ok := sim.(*HashLifeWorld); ok {
+		perTick = h.Step
+	}
+	every := ticks/benchSamples + 1
+
+	var before, m runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	res := benchResult{ticks: ticks, peakHeap: before.HeapInuse}
+	var sampling time.Duration
+	start := time.Now()
+	for i := 0; i < ticks; i++ {
+		t := time.Now()
+		sim.Update(&t)
+		if i%every == 0 {
+			s := time.Now()
+			runtime.ReadMemStats(&m)
+			if m.HeapInuse > res.peakHeap {
+				res.peakHeap = m.HeapInuse
+			}
+			sampling += time.Since(s)
+		}
+	}
+	res.elapsed = time.Since(start) - sampling
+	runtime.ReadMemStats(&m)
+	if m.HeapInuse > res.peakHeap {
+		res.peakHeap = m.HeapInuse
+	}
+	res.generations = int64(ticks) * perTick
+	res.allocs = m.Mallocs - before.Mallocs
+	res.allocBytes = m.TotalAlloc - before.TotalAlloc
+	return res
+}
+
+func (r benchResult) String() string {
+	secs := r.elapsed.Seconds()
+	return fmt.Sprintf("%d generations in %v: %.1f generations/s, %.2f ms/tick, %.1f allocs/tick, %.1f KiB allocated/tick, peak heap %.1f MiB",
+		r.generations, r.elapsed.Round(time.Millisecond), float64(r.generations)/secs,
+		secs*1000/float64(r.ticks), float64(r.allocs)/float64(r.ticks),
+		float64(r.allocBytes)/1024/float64(r.ticks), float64(r.peakHeap)/(1<<20))
+}
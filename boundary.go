@@ -0,0 +1,72 @@
+package main
+
+import "fmt"
+
+// BoundaryMode determines what lies beyond the edges of the world.
+type BoundaryMode int
+
+const (
+	// BoundaryDead treats cells outside the world as permanently dead.
+	BoundaryDead BoundaryMode = iota
+	// BoundaryWrap connects opposite edges like a torus.
+	BoundaryWrap
+	// BoundaryMirror reflects the world across its edges.
+	BoundaryMirror
+	// BoundaryAlwaysAlive treats cells outside the world as permanently alive.
+	BoundaryAlwaysAlive
+)
+
+var boundaryNames = map[BoundaryMode]string{
+	BoundaryDead:        "dead",
+	BoundaryWrap:        "wrap",
+	BoundaryMirror:      "mirror",
+	BoundaryAlwaysAlive: "alive",
+}
+
+func (m BoundaryMode) String() string {
+	if name, ok := boundaryNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("BoundaryMode(%d)", int(m))
+}
+
+// ParseBoundaryMode returns the boundary mode with the given name, as printed
+// by BoundaryMode.String.
+func ParseBoundaryMode(s string) (BoundaryMode, error) {
+	for m, name := range boundaryNames {
+		if name == s {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown boundary mode %q", s)
+}
+
+// resolve maps (x, y) to a cell inside a width×height world. If the
+// coordinate maps outside the world, ok is false and alive reports whether
+// the missing cell should be counted as alive.
+func (m BoundaryMode) resolve(width, height, x, y int) (x2, y2 int, ok, alive bool) {
+	if 0 <= x && 0 <= y && x < width && y < height {
+		return x, y, true, false
+	}
+	switch m {
+	case BoundaryWrap:
+		return (x%width + width) % width, (y%height + height) % height, true, false
+	case BoundaryMirror:
+		return mirror(x, width), mirror(y, height), true, false
+	case BoundaryAlwaysAlive:
+		return 0, 0, false, true
+	default:
+		return 0, 0, false, false
+	}
+}
+
+// mirror reflects i back into [0, n), repeating the edge cell so that -1 maps
+// to 0 and n maps to n-1. Coordinates further out keep reflecting, which
+// matters for neighbourhoods wider than the world.
+func mirror(i, n int) int {
+	i = (i%(2*n) + 2*n) % (2 * n)
+	if i >= n {
+		i = 2*n - i - 1
+	}
+	return i
+}
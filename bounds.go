@@ -0,0 +1,57 @@
+package main
+
+import "image"
+
+// Bounds returns the smallest rectangle containing every cell that isn't
+// dead, or the empty rectangle if all cells are dead. It is recomputed by
+// every Update; cells edited since only ever grow it, so after an edit
+// killed cells it may be larger than necessary until the next Update.
+func (w *World) Bounds() image.Rectangle {
+	return w.bounds
+}
+
+// updateBounds recomputes w.bounds, scanning rows inwards from the top and
+// bottom and then columns inwards from the sides, so that only the cells
+// outside the box and those on its edges are ever looked at.
+func (w *World) updateBounds() {
+	if w.population == 0 {
+		w.bounds = image.Rectangle{}
+		return
+	}
+	rowEmpty := func(y int) bool {
+		for _, s := range w.area[y*w.width : (y+1)*w.width] {
+			if s != Dead {
+				return false
+			}
+		}
+		return true
+	}
+	colEmpty := func(x, y0, y1 int) bool {
+		for y := y0; y < y1; y++ {
+			if w.area[y*w.width+x] != Dead {
+				return false
+			}
+		}
+		return true
+	}
+	y0, y1 := 0, w.height
+	for y0 < y1 && rowEmpty(y0) {
+		y0++
+	}
+	for y1 > y0 && rowEmpty(y1-1) {
+		y1--
+	}
+	x0, x1 := 0, w.width
+	for x0 < x1 && colEmpty(x0, y0, y1) {
+		x0++
+	}
+	for x1 > x0 && colEmpty(x1-1, y0, y1) {
+		x1--
+	}
+	w.bounds = image.Rect(x0, y0, x1, y1)
+}
+
+// growBounds extends w.bounds to take in the cell at index i.
+func (w *World) growBounds(i int) {
+	w.bounds = w.bounds.Union(image.Rect(i%w.width, i/w.width, i%w.width+1, i/w.width+1))
+}
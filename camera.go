@@ -0,0 +1,135 @@
+package main
+
+import (
+	"image"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	// cameraSpeed is the number of pixels the camera moves per frame while
+	// an arrow key is held.
+	cameraSpeed = 4
+	// zoomStep is the factor one notch of the mouse wheel zooms by.
+	zoomStep = 1.25
+	// minZoom and maxZoom bound the size of a cell on the screen, in
+	// pixels.
+	minZoom = 0.25
+	maxZoom = 64
+)
+
+// Camera determines which part of the world the renderer shows, and how
+// large. World coordinates are cells for the worlds drawn one pixel per
+// cell, and the pixels the others are drawn at otherwise.
+type Camera struct {
+	// X and Y are the world coordinates shown at the top-left corner of
+	// the screen.
+	X, Y float64
+	// Zoom is the size on the screen of one unit of world coordinates, in
+	// pixels.
+	Zoom float64
+}
+
+// WorldToScreen returns the screen position of the world position (x, y).
+func (c Camera) WorldToScreen(x, y float64) (sx, sy float64) {
+	return (x - c.X) * c.Zoom, (y - c.Y) * c.Zoom
+}
+
+// ScreenToWorld returns the world position shown at the screen position
+// (sx, sy).
+func (c Camera) ScreenToWorld(sx, sy float64) (x, y float64) {
+	return sx/c.Zoom + c.X, sy/c.Zoom + c.Y
+}
+
+// GeoM returns the transform from world to screen coordinates.
+func (c Camera) GeoM() ebiten.GeoM {
+	var geo ebiten.GeoM
+	geo.Translate(-c.X, -c.Y)
+	geo.Scale(c.Zoom, c.Zoom)
+	return geo
+}
+
+// Pan moves the view by (dx, dy) screen pixels, so that the world moves
+// the other way.
+func (c *Camera) Pan(dx, dy float64) {
+	c.X += dx / c.Zoom
+	c.Y += dy / c.Zoom
+}
+
+// ZoomAt zooms by factor, keeping the world position under the screen
+// position (sx, sy) in place. The zoom stays between minZoom and maxZoom.
+func (c *Camera) ZoomAt(sx, sy, factor float64) {
+	x, y := c.ScreenToWorld(sx, sy)
+	c.Zoom = math.Max(minZoom, math.Min(maxZoom, c.Zoom*factor))
+	c.X, c.Y = x-sx/c.Zoom, y-sy/c.Zoom
+}
+
+// frameOrigin returns the world coordinates of the top-left pixel of the
+// frame: the origin for simulations with fixed bounds, and for those
+// without the cell at the top-left corner of the screen, from which on
+// they are drawn.
+func (r *Renderer) frameOrigin() image.Point {
+	if _, ok := r.sim.(viewSimulation); !ok {
+		return image.Point{}
+	}
+	return image.Pt(int(math.Floor(r.camera.X)), int(math.Floor(r.camera.Y)))
+}
+
+// frameGeoM returns the transform that draws the frame on the screen.
+func (r *Renderer) frameGeoM() ebiten.GeoM {
+	var geo ebiten.GeoM
+	o := r.frameOrigin()
+	geo.Translate(float64(o.X), float64(o.Y))
+	geo.Concat(r.camera.GeoM())
+	return geo
+}
+
+// resetCamera shows the world from the origin at its initial size, see
+// setDeviceScale.
+func (r *Renderer) resetCamera() {
+	r.camera = Camera{Zoom: float64(r.cellSize)}
+	if !r.fitsScreen() {
+		r.camera.Zoom *= r.deviceScale
+	}
+}
+
+// handleCamera zooms with the mouse wheel, around the cursor, and pans by
+// dragging or with the arrow keys. The left mouse button drags unless it
+// is busy painting, dropping sand or held on the minimap; the middle one
+// always does. Home resets the view.
+func (r *Renderer) handleCamera(onMinimap bool) {
+	x, y := ebiten.CursorPosition()
+	if _, dy := ebiten.Wheel(); dy != 0 {
+		r.camera.ZoomAt(float64(x), float64(y), math.Pow(zoomStep, dy))
+	}
+
+	_, sandpile := r.sim.(*SandpileWorld)
+	drag := ebiten.IsMouseButtonPressed(ebiten.MouseButtonMiddle) ||
+		ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) && !r.Editing() && !sandpile && !onMinimap
+	if drag && r.drag.active {
+		r.camera.Pan(float64(r.drag.from.X-x), float64(r.drag.from.Y-y))
+	}
+	r.drag.active, r.drag.from = drag, image.Pt(x, y)
+
+	speed := float64(cameraSpeed)
+	if ebiten.IsKeyPressed(ebiten.KeyShift) {
+		speed *= 8
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyArrowLeft) {
+		r.camera.Pan(-speed, 0)
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyArrowRight) {
+		r.camera.Pan(speed, 0)
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyArrowUp) {
+		r.camera.Pan(0, -speed)
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyArrowDown) {
+		r.camera.Pan(0, speed)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyHome) {
+		r.resetCamera()
+	}
+}
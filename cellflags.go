@@ -0,0 +1,87 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/fogleman/gg"
+)
+
+// CellFlags mark cells that don't follow the rule.
+type CellFlags uint8
+
+const (
+	// CellWall marks a cell that is permanently dead, so it never counts
+	// as a live neighbour either. Walls are used to build arenas.
+	CellWall CellFlags = 1 << iota
+	// CellImmortal marks a cell that is permanently alive.
+	CellImmortal
+)
+
+var (
+	wallColor     = color.RGBA{0x60, 0x60, 0x60, 0xff}
+	immortalColor = color.RGBA{0xff, 0xd7, 0x00, 0xff}
+)
+
+// SetCellFlags replaces the flags of the cell at (x, y) and puts the cell
+// in the state they demand right away.
+func (w *World) SetCellFlags(x, y int, f CellFlags) {
+	if x < 0 || y < 0 || x >= w.width || y >= w.height {
+		return
+	}
+	if w.flags == nil {
+		if f == 0 {
+			return
+		}
+		w.flags = make([]CellFlags, len(w.area))
+	}
+	i := y*w.width + x
+	w.flags[i] = f
+	w.applyFlag(i)
+}
+
+// CellFlags returns the flags of the cell at (x, y).
+func (w *World) CellFlags(x, y int) CellFlags {
+	if w.flags == nil || x < 0 || y < 0 || x >= w.width || y >= w.height {
+		return 0
+	}
+	return w.flags[y*w.width+x]
+}
+
+// applyCellFlags overrides whatever the rule did to flagged cells.
+func (w *World) applyCellFlags() {
+	if w.flags == nil {
+		return
+	}
+	for i, f := range w.flags {
+		if f != 0 {
+			w.applyFlag(i)
+		}
+	}
+}
+
+func (w *World) applyFlag(i int) {
+	switch {
+	case w.flags[i]&CellWall != 0:
+		w.set(i, Dead)
+	case w.flags[i]&CellImmortal != 0:
+		w.set(i, Alive)
+	}
+}
+
+// drawCellFlags paints walls and immortal cells in their own colors.
+func (w *World) drawCellFlags(dc *gg.Context) {
+	if w.flags == nil {
+		return
+	}
+	for i, f := range w.flags {
+		switch {
+		case f&CellWall != 0:
+			dc.SetColor(wallColor)
+		case f&CellImmortal != 0:
+			dc.SetColor(immortalColor)
+		default:
+			continue
+		}
+		dc.SetPixel(i%w.width, i/w.width)
+	}
+}
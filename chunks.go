@@ -0,0 +1,112 @@
+package main
+
+import "image"
+
+// chunkSize is the side in cells of the square chunks whose activity the
+// world tracks. It must be at least the radius of every neighbourhood.
+const chunkSize = 16
+
+// chunkTracker remembers the input of the previous generation, so that the
+// next one can tell which chunks of the world have changed since.
+type chunkTracker struct {
+	last      []State
+	rule      Rule
+	zones     []uint8
+	boundary  BoundaryMode
+	neighbour Neighbourhood
+	// dirty is scratch space for the chunks that need updating.
+	dirty []bool
+}
+
+// deterministic reports whether the next state under rule only depends on
+// a cell and its neighbours, and not on chance or the passing of time.
+func deterministic(rule Rule) bool {
+	switch rule.(type) {
+	case *StochasticRule, *ForestFireRule, tickingRule:
+		return false
+	}
+	return true
+}
+
+// dirtyChunks returns which chunks, in row-major order, may change in this
+// generation, or nil if all of them may. A chunk can only change if a cell
+// in it or in one of the chunks around it changed since the input of the
+// previous generation; otherwise it sees the same input again and, under a
+// deterministic rule, produces the same output, which is its current
+// state. The chunks along the edges are always updated, since cells beyond
+// the edges, like wrapped ones, may be far away.
+//
+// That only holds while the current state is what the rule made of the
+// previous input, so every chunk is updated while noise, MaxAge, turmites
+// or cell flags change cells after the rule has run. Every chunk is also
+// updated if FullUpdates is set.
+func (w *World) dirtyChunks() []bool {
+	t := &w.chunks
+	defer func() {
+		t.last = append(t.last[:0], w.area...)
+		t.rule, t.boundary, t.neighbour = w.rule, w.Boundary, w.Neighbourhood
+		t.zones = append(t.zones[:0], w.zones...)
+	}()
+	if w.FullUpdates || len(t.last) != len(w.area) || t.rule != w.rule || t.boundary != w.Boundary || t.neighbour != w.Neighbourhood ||
+		string(t.zones) != string(w.zones) || !deterministic(w.rule) || w.changesAfterRule() {
+		return nil
+	}
+	for _, r := range w.zoneRules {
+		if !deterministic(r) {
+			return nil
+		}
+	}
+
+	cw, ch := chunkGrid(w.width, w.height)
+	changed := make([]bool, cw*ch)
+	for i, s := range w.area {
+		if s != t.last[i] {
+			x, y := i%w.width, i/w.width
+			changed[y/chunkSize*cw+x/chunkSize] = true
+		}
+	}
+	if cap(t.dirty) < len(changed) {
+		t.dirty = make([]bool, len(changed))
+	}
+	dirty := t.dirty[:len(changed)]
+	for cy := 0; cy < ch; cy++ {
+		for cx := 0; cx < cw; cx++ {
+			d := cx == 0 || cy == 0 || cx == cw-1 || cy == ch-1
+			for dy := -1; dy <= 1 && !d; dy++ {
+				for dx := -1; dx <= 1 && !d; dx++ {
+					d = changed[(cy+dy)*cw+cx+dx]
+				}
+			}
+			dirty[cy*cw+cx] = d
+		}
+	}
+	return dirty
+}
+
+// invalidate makes the next generation update every chunk, for when cells
+// have been changed other than by the rule, like by editing or restoring a
+// snapshot. Otherwise a chunk that was put back into the state it had in
+// the previous input would be taken for one that hasn't changed.
+func (t *chunkTracker) invalidate() {
+	t.last = t.last[:0]
+}
+
+// changesAfterRule reports whether any of the steps of a tick that follow
+// the rule may change cells: noise, dying of old age, turmites and cell
+// flags.
+func (w *World) changesAfterRule() bool {
+	return w.Noise > 0 || w.MaxAge > 0 || (len(w.turmites) > 0 && w.AntSteps > 0) || w.flags != nil
+}
+
+// chunkGrid returns the number of columns and rows of chunks that cover a
+// width×height world. The last column and row may be partial.
+func chunkGrid(width, height int) (cw, ch int) {
+	return (width + chunkSize - 1) / chunkSize, (height + chunkSize - 1) / chunkSize
+}
+
+// chunkRect returns the cells covered by chunk c of a width×height world.
+func chunkRect(c, width, height int) image.Rectangle {
+	cw, _ := chunkGrid(width, height)
+	r := image.Rect(0, 0, chunkSize, chunkSize).Add(image.Pt(c%cw, c/cw).Mul(chunkSize))
+	return r.Intersect(image.Rect(0, 0, width, height))
+}
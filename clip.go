@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// clipBacklog is how many captured frames can wait for the encoder before
+// the recorder starts dropping them, rather than hold up Draw.
+const clipBacklog = 8
+
+// clipFormats are the formats clips can be recorded in, all of them
+// encoded in Go, and the encoders of the clips c records in them.
+var clipFormats = map[string]func(c *clipRecorder) clipEncoder{
+	"apng": func(*clipRecorder) clipEncoder { return &apngEncoder{} },
+	"gif": func(c *clipRecorder) clipEncoder {
+		return &gifEncoder{palette: gifPalettes[c.GIFPalette], colors: c.GIFColors}
+	},
+	"webp": func(*clipRecorder) clipEncoder { return &webpEncoder{} },
+}
+
+// clipFormatNames returns the names of clipFormats in sorted order.
+func clipFormatNames() []string {
+	names := make([]string, 0, len(clipFormats))
+	for name := range clipFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// clipEncoder encodes the frames of a clip as they are captured, and
+// writes the file once the last one is in.
+type clipEncoder interface {
+	// add encodes img, to be shown for delay. It must not retain img.
+	add(img *image.RGBA, delay time.Duration) error
+	// write writes the clip to w.
+	write(w io.Writer) error
+}
+
+// clipFrame is a frame of the screen captured for a clip, and the time it
+// was captured.
+type clipFrame struct {
+	img *image.RGBA
+	at  time.Time
+}
+
+// clipRecorder records the screen as an animated image. Draw captures a
+// frame every Skip+1 frames and hands it to an encoder goroutine, which
+// encodes it right away and writes the file once recording stops, so that
+// neither stalls the game loop.
+type clipRecorder struct {
+	// Skip is the number of frames left out after every frame recorded,
+	// and Format one of clipFormats. GIFPalette is the name of one of
+	// gifPalettes and GIFColors the size of the adaptive one.
+	Skip       int
+	Format     string
+	GIFPalette string
+	GIFColors  int
+
+	// frames is the queue of the encoder, nil while not recording, and
+	// size the size of the frames it takes.
+	frames  chan clipFrame
+	size    image.Point
+	count   int
+	dropped int
+	// free are captured frames the encoder is done with, for reuse.
+	free chan *image.RGBA
+	// results reports the outcome of every recording once it is written.
+	results recordingResults
+}
+
+// recording reports whether frames are being recorded.
+func (c *clipRecorder) recording() bool {
+	return c.frames != nil
+}
+
+// start starts recording frames of the given size into the file name.
+func (c *clipRecorder) start(name string, size image.Point) {
+	if c.free == nil {
+		c.free = make(chan *image.RGBA, clipBacklog)
+	}
+	c.frames = make(chan clipFrame, clipBacklog)
+	c.size, c.count, c.dropped = size, 0, 0
+	go c.encode(name, clipFormats[c.Format](c), c.frames)
+}
+
+// stop stops recording, leaving the encoder to finish the file.
+func (c *clipRecorder) stop() {
+	close(c.frames)
+	c.frames = nil
+}
+
+// capture records screen if recording and it is due, or drops the frame if
+// the encoder has fallen behind. It stops recording and returns an error
+// if the screen changed size, since the frames of a clip all share the
+// size of the first.
+func (c *clipRecorder) capture(screen *ebiten.Image) error {
+	if !c.recording() {
+		return nil
+	}
+	if screen.Bounds().Size() != c.size {
+		c.stop()
+		return errScreenResized
+	}
+	c.count++
+	if (c.count-1)%(c.Skip+1) != 0 {
+		return nil
+	}
+	if len(c.frames) == cap(c.frames) {
+		c.dropped++
+		return nil
+	}
+	var dst *image.RGBA
+	select {
+	case dst = <-c.free:
+	default:
+	}
+	c.frames <- clipFrame{captureScreen(screen, dst), time.Now()}
+	return nil
+}
+
+// encode encodes the frames with enc as they arrive and writes the clip to
+// the file name once frames is closed. Each frame is shown for as long as
+// it took to capture the next one, so a frame is only encoded once the
+// next one is in; the last one is shown as long as the one before.
+func (c *clipRecorder) encode(name string, enc clipEncoder, frames <-chan clipFrame) {
+	var prev clipFrame
+	delay := 100 * time.Millisecond
+	n := 0
+	var err error
+	add := func(img *image.RGBA) {
+		if err == nil {
+			err = enc.add(img, delay)
+		}
+		n++
+		select {
+		case c.free <- img:
+		default:
+		}
+	}
+	for f := range frames {
+		if prev.img != nil {
+			delay = f.at.Sub(prev.at)
+			add(prev.img)
+		}
+		prev = f
+	}
+	if prev.img == nil {
+		c.results.report("nothing recorded in %s", name)
+		return
+	}
+	add(prev.img)
+	if err == nil {
+		err = writeClip(name, enc)
+	}
+	if err != nil {
+		c.results.report("recording %s failed: %v", name, err)
+		return
+	}
+	c.results.report("saved %s, %d frames", name, n)
+}
+
+// writeClip writes the clip encoded by enc to a file called name.
+func writeClip(name string, enc clipEncoder) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := enc.write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// captureClip records the screen if a clip is being recorded, with a toast
+// if recording had to stop.
+func (r *Renderer) captureClip(screen *ebiten.Image) {
+	if err := r.clip.capture(screen); err != nil {
+		r.showToast("recording stopped: %v", err)
+	}
+}
+
+// handleRecording starts and stops recording a clip with the C key, and
+// shows a toast once the encoder has written it.
+func (r *Renderer) handleRecording() {
+	c := &r.clip
+	if msg, ok := c.results.take(); ok {
+		r.showToast("%s", msg)
+	}
+	if !inpututil.IsKeyJustPressed(ebiten.KeyC) {
+		return
+	}
+	if c.recording() {
+		c.stop()
+		r.showToast("encoding %s", strings.ToUpper(c.Format))
+		return
+	}
+	c.start(r.captureName("."+c.Format), r.screen)
+	r.showToast("recording %s, C to stop", strings.ToUpper(c.Format))
+}
+
+// recordingSummary describes the recording in progress for the HUD, or
+// returns "" if there is none.
+func (r *Renderer) recordingSummary() string {
+	c := &r.clip
+	if !c.recording() {
+		return ""
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "recording %s, %d frames", strings.ToUpper(c.Format), (c.count+c.Skip)/(c.Skip+1)-c.dropped)
+	if c.dropped > 0 {
+		fmt.Fprintf(&sb, ", %d dropped", c.dropped)
+	}
+	sb.WriteString(", C to stop")
+	return sb.String()
+}
@@ -0,0 +1,127 @@
+package main
+
+import "fmt"
+
+// ColorRule is a colored variant of a Life-like rule: every live cell
+// carries one of several colors, stored as the states Alive to
+// Alive+colors-1. Cells are born and survive exactly as under the
+// underlying rule, counting live neighbours of any color, and a newborn
+// takes the majority color of its live neighbours.
+type ColorRule struct {
+	LifeRule
+	colors int
+}
+
+// Immigration is Conway's Life with two colors.
+var Immigration = NewColorRule(Conway, 2)
+
+// QuadLife is Conway's Life with four colors.
+var QuadLife = NewColorRule(Conway, 4)
+
+// NewColorRule returns a colored variant of rule with the given number of
+// live colors, between 2 and 8.
+func NewColorRule(rule *LifeRule, colors int) *ColorRule {
+	if colors < 2 || colors > 8 {
+		panic(fmt.Sprintf("color rule: %d colors out of range", colors))
+	}
+	return &ColorRule{LifeRule: *rule, colors: colors}
+}
+
+// Colors returns the number of live colors.
+func (r *ColorRule) Colors() int {
+	return r.colors
+}
+
+// States returns the number of cell states of the rule, Dead included.
+func (r *ColorRule) States() int {
+	return r.colors + 1
+}
+
+// Next implements Rule. When the live neighbours have no single majority
+// color and exactly one color is missing among them, as with three
+// differently colored parents in QuadLife, the newborn takes the missing
+// color; any other tie goes to the lowest color.
+//
+// States that aren't one of the colors, such as those painted by turmites
+// or left over from another rule, count as dead.
+func (r *ColorRule) Next(self State, neighbours []State) State {
+	var counts [9]int
+	n := 0
+	for _, s := range neighbours {
+		if r.colored(s) {
+			counts[s-Alive]++
+			n++
+		}
+	}
+	if !r.colored(self) {
+		self = Dead
+	}
+	if n >= len(r.birth) {
+		return Dead
+	}
+	if self != Dead {
+		if r.survival[n] {
+			return self
+		}
+		return Dead
+	}
+	if !r.birth[n] {
+		return Dead
+	}
+	best, tie, missing, absent := 0, false, 0, 0
+	for c := 0; c < r.colors; c++ {
+		switch {
+		case counts[c] == 0:
+			missing = c
+			absent++
+		case counts[c] > counts[best]:
+			best, tie = c, false
+		case c != best && counts[c] == counts[best]:
+			tie = true
+		}
+	}
+	if tie && absent == 1 {
+		best = missing
+	}
+	return Alive + State(best)
+}
+
+// colored reports whether s is one of the live colors of the rule.
+func (r *ColorRule) colored(s State) bool {
+	return s >= Alive && int(s-Alive) < r.colors
+}
+
+// String returns the underlying rule in B/S notation followed by the number
+// of colors.
+func (r *ColorRule) String() string {
+	return fmt.Sprintf("%s/Q%d", r.LifeRule.String(), r.colors)
+}
+
+// multiColorRule is implemented by rules whose live cells come in several
+// colors, stored as the states Alive to Alive+Colors()-1.
+type multiColorRule interface {
+	Rule
+	Colors() int
+}
+
+// colorize gives every live cell a uniformly random one of the colors.
+func (w *World) colorize(colors int) {
+	for i, s := range w.area {
+		if s != Dead {
+			w.area[i] = Alive + State(w.rng.Intn(colors))
+		}
+	}
+	w.chunks.invalidate()
+}
+
+// Populations returns the number of cells in each state below states,
+// indexed by state.
+func (w *World) Populations(states int) []int {
+	counts := make([]int, states)
+	for _, s := range w.area {
+		if int(s) < states {
+			counts[s]++
+		}
+	}
+	return counts
+}
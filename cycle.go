@@ -0,0 +1,80 @@
+package main
+
+// cycleHistory is the number of generations a cycleDetector remembers, and
+// so the longest period it can find.
+const cycleHistory = 128
+
+// cycleDetector notices when a world returns to a generation it has been
+// in recently, from the hashes of the generations.
+type cycleDetector struct {
+	// hashes is a ring of the hashes of the last cycleHistory generations,
+	// indexed by generation modulo cycleHistory, and seen maps each of them
+	// to its latest generation.
+	hashes     [cycleHistory]uint64
+	seen       map[uint64]int
+	generation int
+}
+
+// observe records the next generation and returns the period of the cycle
+// it belongs to, or 0 if it hasn't occurred in the remembered history.
+// Hashes can collide, so a period is very likely but not certain.
+func (d *cycleDetector) observe(cells []State) int {
+	if d.seen == nil {
+		d.seen = make(map[uint64]int, cycleHistory)
+	}
+	h := hashCells(cells)
+	period := 0
+	if g, ok := d.seen[h]; ok {
+		period = d.generation - g
+	}
+	slot := d.generation % cycleHistory
+	if d.generation >= cycleHistory {
+		if old := d.hashes[slot]; d.seen[old] == d.generation-cycleHistory {
+			delete(d.seen, old)
+		}
+	}
+	d.hashes[slot] = h
+	d.seen[h] = d.generation
+	d.generation++
+	return period
+}
+
+// reset forgets all generations.
+func (d *cycleDetector) reset() {
+	*d = cycleDetector{}
+}
+
+// FNV-1a parameters, see hashCells.
+const (
+	fnvOffset = 14695981039346656037
+	fnvPrime  = 1099511628211
+)
+
+// hashCells returns the 64-bit FNV-1a hash of the states of cells.
+func hashCells(cells []State) uint64 {
+	return addCells(fnvOffset, cells)
+}
+
+// addCells adds the states of cells to the FNV-1a hash h.
+func addCells(h uint64, cells []State) uint64 {
+	for _, s := range cells {
+		h ^= uint64(s)
+		h *= fnvPrime
+	}
+	return h
+}
+
+// Hash returns a 64-bit hash of the world's size and cell states, to tell
+// whether two worlds are in the same state without comparing every cell.
+// It doesn't depend on the platform or the run, so hashes can also be
+// stored to check that a replay ended up where the original did.
+func (w *World) Hash() uint64 {
+	h := uint64(fnvOffset)
+	for _, n := range []int{w.width, w.height} {
+		for i := 0; i < 4; i++ {
+			h ^= uint64(n >> (8 * i) & 0xff)
+			h *= fnvPrime
+		}
+	}
+	return addCells(h, w.area)
+}
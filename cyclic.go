@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CyclicRule is a cyclic cellular automaton. Every cell holds one of n
+// states arranged in a cycle, and advances to the successor of its state
+// when at least threshold of its neighbours already hold that successor.
+// Started from a uniformly random soup it settles into spiral waves.
+type CyclicRule struct {
+	states    int
+	threshold int
+}
+
+// CyclicSpirals is the "313" rule, which organizes into spirals quickly.
+var CyclicSpirals = MustParseCyclicRule("R1/T3/C3/NM")
+
+// ParseCyclicRule parses a cyclic rule in MCell notation, e.g.
+// "R1/T3/C3/NM" for range 1, threshold 3 and 3 states on the Moore
+// neighbourhood. Only range 1 and the Moore neighbourhood are supported.
+func ParseCyclicRule(s string) (*CyclicRule, error) {
+	r := &CyclicRule{}
+	fields := strings.Split(strings.TrimSpace(s), "/")
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("cyclic rule %q: expected R/T/C/N fields", s)
+	}
+	for _, f := range fields {
+		if f == "" {
+			return nil, fmt.Errorf("cyclic rule %q: empty field", s)
+		}
+		key, val := strings.ToUpper(f[:1]), f[1:]
+		var err error
+		switch key {
+		case "R":
+			if val != "1" {
+				err = fmt.Errorf("only range 1 is supported")
+			}
+		case "T":
+			r.threshold, err = strconv.Atoi(val)
+			if err == nil && (r.threshold < 1 || r.threshold > 8) {
+				err = fmt.Errorf("threshold out of range")
+			}
+		case "C":
+			r.states, err = strconv.Atoi(val)
+			if err == nil && (r.states < 2 || r.states > 256) {
+				err = fmt.Errorf("state count out of range")
+			}
+		case "N":
+			if !strings.EqualFold(val, "M") {
+				err = fmt.Errorf("unsupported neighbourhood %q", val)
+			}
+		default:
+			err = fmt.Errorf("unknown field")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cyclic rule %q: %s: %v", s, f, err)
+		}
+	}
+	if r.states == 0 || r.threshold == 0 {
+		return nil, fmt.Errorf("cyclic rule %q: threshold and state count are required", s)
+	}
+	return r, nil
+}
+
+// MustParseCyclicRule is like ParseCyclicRule but panics if the rulestring
+// is invalid.
+func MustParseCyclicRule(s string) *CyclicRule {
+	r, err := ParseCyclicRule(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// States returns the number of cell states of the rule.
+func (r *CyclicRule) States() int {
+	return r.states
+}
+
+// Next implements Rule.
+func (r *CyclicRule) Next(self State, neighbours []State) State {
+	succ := State((int(self) + 1) % r.states)
+	n := 0
+	for _, s := range neighbours {
+		if s == succ {
+			n++
+		}
+	}
+	if n >= r.threshold {
+		return succ
+	}
+	return self
+}
+
+// String returns the rule in MCell notation.
+func (r *CyclicRule) String() string {
+	return fmt.Sprintf("R1/T%d/C%d/NM", r.threshold, r.states)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	// debugMemEvery is the number of frames the memory statistics of the
+	// debug overlay are reused for, since reading them stops the world.
+	debugMemEvery = 30
+	// debugWidth is the width in pixels kept free for the debug overlay at
+	// the right edge of the screen.
+	debugWidth = 240
+)
+
+// debugOverlay measures how long the renderer takes per frame, for the
+// overlay F3 shows.
+type debugOverlay struct {
+	shown bool
+	// update and draw are how long the last Update and Draw took, and
+	// upload how long the last frame took to copy to its texture.
+	update, draw, upload time.Duration
+	// mem is the memory statistics as of frame, counted in Draws.
+	mem   runtime.MemStats
+	frame int
+}
+
+// lines returns the text of the overlay.
+func (d *debugOverlay) lines() []string {
+	m := &d.mem
+	lastPause := time.Duration(m.PauseNs[(m.NumGC+255)%256])
+	return []string{
+		fmt.Sprintf("update %v", d.update.Round(time.Microsecond)),
+		fmt.Sprintf("draw %v", d.draw.Round(time.Microsecond)),
+		fmt.Sprintf("upload %v", d.upload.Round(time.Microsecond)),
+		fmt.Sprintf("goroutines %d", runtime.NumGoroutine()),
+		fmt.Sprintf("heap %.1f MiB, %d objects", float64(m.HeapAlloc)/(1<<20), m.HeapObjects),
+		fmt.Sprintf("GC %d cycles, %.1f%% CPU", m.NumGC, 100*m.GCCPUFraction),
+		fmt.Sprintf("GC pause %v last, %v total", lastPause.Round(time.Microsecond), time.Duration(m.PauseTotalNs).Round(time.Microsecond)),
+	}
+}
+
+// drawDebug draws the debug overlay in the top-right corner of the screen,
+// if it is shown.
+func (r *Renderer) drawDebug(screen *ebiten.Image) {
+	d := &r.debug
+	if !d.shown {
+		return
+	}
+	if d.frame%debugMemEvery == 0 {
+		runtime.ReadMemStats(&d.mem)
+	}
+	d.frame++
+	ebitenutil.DebugPrintAt(screen, strings.Join(d.lines(), "\n"), r.screen.X-debugWidth, 0)
+}
+
+// handleDebug shows and hides the debug overlay with the F3 key.
+func (r *Renderer) handleDebug() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyF3) {
+		r.debug.shown = !r.debug.shown
+		r.debug.frame = 0
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// brush tracks the mouse while painting, so that a fast drag between two
+// polls still paints a continuous line.
+type brush struct {
+	down bool
+	last image.Point
+	// zones makes the brush paint zones rather than cells, and zone is the
+	// zone it paints.
+	zones bool
+	zone  int
+}
+
+// digitKeys select the zone painted by the zone brush.
+var digitKeys = []ebiten.Key{
+	ebiten.Key0, ebiten.Key1, ebiten.Key2, ebiten.Key3, ebiten.Key4,
+	ebiten.Key5, ebiten.Key6, ebiten.Key7, ebiten.Key8, ebiten.Key9,
+}
+
+// Editing reports whether the renderer is in editing mode.
+func (r *Renderer) Editing() bool {
+	return r.editing.Load().(bool)
+}
+
+// sandpileDrop is the number of grains the left mouse button drops on a
+// sandpile every frame it is held.
+const sandpileDrop = 4
+
+// handleEditing toggles editing mode with the E key and, while editing,
+// turns mouse input into paint edits for the world update loop. Sandpiles
+// need no editing mode: holding the left mouse button drops grains.
+func (r *Renderer) handleEditing() {
+	if s, ok := r.sim.(*SandpileWorld); ok {
+		if p := r.cursorCell(); ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) && p.X >= 0 && p.Y >= 0 {
+			r.queueEdit(func() {
+				s.Drop(p.X/s.Scale(), p.Y/s.Scale(), sandpileDrop)
+			})
+		}
+		return
+	}
+	w, ok := r.sim.(*World)
+	if !ok || w.Grid() != SquareGrid {
+		// Only square grids map the cursor straight onto cells.
+		return
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyE) {
+		r.editing.Store(!r.Editing())
+		r.brush.down = false
+	}
+	if !r.Editing() {
+		return
+	}
+	if w.Zones() > 1 {
+		if inpututil.IsKeyJustPressed(ebiten.KeyZ) {
+			r.brush.zones = !r.brush.zones
+		}
+		for z, k := range digitKeys[:w.Zones()] {
+			if inpututil.IsKeyJustPressed(k) {
+				r.brush.zone = z
+			}
+		}
+	}
+	if r.brush.zones {
+		if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+			r.brush.down = false
+			return
+		}
+		from, to, z := r.brush.stroke(r.cursorCell()), r.brush.last, r.brush.zone
+		r.queueEdit(func() {
+			plotLine(from, to, func(p image.Point) {
+				w.PaintZone(p.X, p.Y, z)
+			})
+		})
+		return
+	}
+
+	paint, alt := brushStates(w.rule)
+	var s State
+	var f CellFlags
+	switch {
+	case ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) && ebiten.IsKeyPressed(ebiten.KeyW):
+		f = CellWall
+	case ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) && ebiten.IsKeyPressed(ebiten.KeyI):
+		f = CellImmortal
+	case ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) && ebiten.IsKeyPressed(ebiten.KeyShift):
+		s = alt
+	case ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft):
+		s = paint
+	case ebiten.IsMouseButtonPressed(ebiten.MouseButtonRight):
+		s = Dead
+	default:
+		r.brush.down = false
+		return
+	}
+
+	from, to := r.brush.stroke(r.cursorCell()), r.brush.last
+	r.queueEdit(func() {
+		if f == 0 {
+			paintLine(w, from, to, s)
+		}
+		if f != 0 || s == Dead {
+			// Erasing also removes walls and immortal cells.
+			plotLine(from, to, func(p image.Point) {
+				w.SetCellFlags(p.X, p.Y, f)
+			})
+		}
+	})
+}
+
+// brushStates returns the states the brush paints in a world running rule:
+// conductor, and electron heads with Shift, in Wireworld, and live cells
+// in every other rule, which count as neighbours there.
+func brushStates(rule Rule) (paint, alt State) {
+	if rule == Wireworld {
+		return WireConductor, WireHead
+	}
+	return Alive, Alive
+}
+
+// stroke moves the pressed brush to p and returns where it moved from,
+// which is p itself at the start of a stroke.
+func (b *brush) stroke(p image.Point) image.Point {
+	from := p
+	if b.down {
+		from = b.last
+	}
+	b.down = true
+	b.last = p
+	return from
+}
+
+// cursorCell returns the world coordinates under the cursor, which are the
+// cell of a square grid.
+func (r *Renderer) cursorCell() image.Point {
+	x, y := ebiten.CursorPosition()
+	wx, wy := r.camera.ScreenToWorld(float64(x), float64(y))
+	return image.Pt(int(math.Floor(wx)), int(math.Floor(wy)))
+}
+
+// editHelp describes the editing controls for w.
+func (r *Renderer) editHelp(w *World) string {
+	if r.brush.zones {
+		return fmt.Sprintf("EDIT ZONES: LMB paint zone %d, 0-%d pick zone, Z edit cells, E to resume", r.brush.zone, w.Zones()-1)
+	}
+	paint := "EDIT: LMB live cell, RMB erase"
+	if w.rule == Wireworld {
+		paint = "EDIT: LMB conductor, Shift+LMB electron, RMB erase"
+	}
+	if w.Zones() > 1 {
+		return paint + "\nW+LMB wall, I+LMB immortal, Z edit zones, E to resume"
+	}
+	return paint + "\nW+LMB wall, I+LMB immortal, E to resume"
+}
+
+// handleReverse toggles the direction of time with the R key in worlds
+// running a reversible block rule.
+func (r *Renderer) handleReverse() {
+	w, ok := r.sim.(*World)
+	if !ok || !inpututil.IsKeyJustPressed(ebiten.KeyR) {
+		return
+	}
+	r.queueEdit(func() {
+		if br, ok := w.rule.(*BlockRule); ok && br.Reversible() {
+			w.Reverse = !w.Reverse
+		}
+	})
+}
+
+// fastForwardSteps is the number of generations the F key skips ahead.
+const fastForwardSteps = 100
+
+// handleFastForward advances a world by fastForwardSteps generations at
+// once with the F key, whether or not it is paused.
+func (r *Renderer) handleFastForward() {
+	w, ok := r.sim.(*World)
+	if !ok || !inpututil.IsKeyJustPressed(ebiten.KeyF) {
+		return
+	}
+	r.queueEdit(func() {
+		w.Step(fastForwardSteps)
+		r.afterUpdate()
+	})
+}
+
+// Paused reports whether the simulation is paused.
+func (r *Renderer) Paused() bool {
+	return r.paused.Load().(bool)
+}
+
+// handlePause pauses and resumes the simulation with the Space key.
+func (r *Renderer) handlePause() {
+	if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+		r.paused.Store(!r.Paused())
+	}
+}
+
+// queueEdit hands edit to the world update loop. Edits are dropped rather
+// than blocking the render loop if the world loop falls behind.
+func (r *Renderer) queueEdit(edit func()) {
+	select {
+	case r.edits <- edit:
+	default:
+	}
+}
+
+// paintLine sets every cell on the line from p0 to p1 to s.
+func paintLine(w *World, p0, p1 image.Point, s State) {
+	plotLine(p0, p1, func(p image.Point) {
+		w.SetState(p.X, p.Y, s)
+	})
+}
+
+// plotLine calls plot for every point on the line from p0 to p1, using
+// Bresenham's algorithm.
+func plotLine(p0, p1 image.Point, plot func(image.Point)) {
+	dx, dy := abs(p1.X-p0.X), -abs(p1.Y-p0.Y)
+	sx, sy := 1, 1
+	if p0.X > p1.X {
+		sx = -1
+	}
+	if p0.Y > p1.Y {
+		sy = -1
+	}
+	err := dx + dy
+	for {
+		plot(p0)
+		if p0 == p1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			p0.X += sx
+		}
+		if e2 <= dx {
+			err += dx
+			p0.Y += sy
+		}
+	}
+}
+
+func abs(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+// ElementaryWorld runs a one-dimensional elementary cellular automaton.
+// Each tick computes one new generation, and the world keeps as many past
+// generations as it has rows, so that drawing it shows time flowing down
+// the screen. Once the history is full the oldest rows scroll off the top.
+type ElementaryWorld struct {
+	// rows is a ring buffer of generations; rows[head] is the oldest.
+	rows   [][]State
+	head   int
+	width  int
+	height int
+	rule   uint8
+
+	// Boundary determines how cells beyond the ends of a row are resolved.
+	Boundary BoundaryMode
+}
+
+// NewElementaryWorld creates a width cells wide elementary automaton
+// running Wolfram rule number rule, keeping height generations of history.
+// It starts from a single live cell in the middle.
+func NewElementaryWorld(width, height int, rule uint8) *ElementaryWorld {
+	first := make([]State, width)
+	first[width/2] = Alive
+	return &ElementaryWorld{
+		rows:   [][]State{first},
+		width:  width,
+		height: height,
+		rule:   rule,
+	}
+}
+
+// cell returns the state of cell x of row, resolving cells beyond the ends
+// according to the boundary mode.
+func (e *ElementaryWorld) cell(row []State, x int) State {
+	x2, _, ok, alive := e.Boundary.resolve(e.width, 1, x, 0)
+	switch {
+	case ok:
+		return row[x2]
+	case alive:
+		return Alive
+	}
+	return Dead
+}
+
+// Update computes the next generation from the newest one.
+func (e *ElementaryWorld) Update(t *time.Time) {
+	last := e.rows[(e.head+len(e.rows)-1)%len(e.rows)]
+
+	var next []State
+	if len(e.rows) < e.height {
+		next = make([]State, e.width)
+		e.rows = append(e.rows, next)
+	} else {
+		// Reuse the oldest row, which scrolls out of view.
+		next = e.rows[e.head]
+		e.head = (e.head + 1) % len(e.rows)
+	}
+
+	for x := range next {
+		var pattern uint
+		for i := -1; i <= 1; i++ {
+			pattern <<= 1
+			if e.cell(last, x+i) == Alive {
+				pattern |= 1
+			}
+		}
+		next[x] = Dead
+		if e.rule&(1<<pattern) != 0 {
+			next[x] = Alive
+		}
+	}
+}
+
+// Draw renders the history, oldest generation at the top.
+func (e *ElementaryWorld) Draw(dc *gg.Context, p palette) {
+	dc.SetColor(p.color(Alive))
+	for y := range e.rows {
+		row := e.rows[(e.head+y)%len(e.rows)]
+		for x, s := range row {
+			if s == Alive {
+				dc.SetPixel(x, y)
+			}
+		}
+	}
+}
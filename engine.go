@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// Engine is a backend running a Life-like rule on the Moore neighbourhood.
+// All engines compute the same generations from the same cells, so they
+// can be swapped for one another with -engine.
+type Engine interface {
+	Simulation
+	// Alive reports whether the cell at (x, y) is alive.
+	Alive(x, y int) bool
+	// Set makes the cell at (x, y) alive or dead.
+	Set(x, y int, alive bool)
+}
+
+// newEngineFunc creates an engine for a width×height world running rule,
+// with up to cells random live cells. Engines with edges resolve the cells
+// beyond them by boundary; unbounded ones seed the cells within the same
+// rectangle and ignore it.
+type newEngineFunc func(width, height, cells int, rule *LifeRule, boundary BoundaryMode) (Engine, error)
+
+// engines are the engines selectable with -engine, by name.
+var engines = map[string]newEngineFunc{
+	"naive": func(width, height, cells int, rule *LifeRule, boundary BoundaryMode) (Engine, error) {
+		w := NewWorld(width, height, cells)
+		w.SetRule(rule)
+		w.Boundary = boundary
+		w.Workers = 1
+		w.FullUpdates = true
+		return w, nil
+	},
+	"parallel": func(width, height, cells int, rule *LifeRule, boundary BoundaryMode) (Engine, error) {
+		w := NewWorld(width, height, cells)
+		w.SetRule(rule)
+		w.Boundary = boundary
+		w.Workers = runtime.NumCPU()
+		return w, nil
+	},
+	"bitset": func(width, height, cells int, rule *LifeRule, boundary BoundaryMode) (Engine, error) {
+		b := NewBitWorld(width, height, cells, rule)
+		b.Boundary = boundary
+		return b, nil
+	},
+	"sparse": func(width, height, cells int, rule *LifeRule, boundary BoundaryMode) (Engine, error) {
+		return NewSparseWorld(width, height, cells, rule), nil
+	},
+	"hashlife": func(width, height, cells int, rule *LifeRule, boundary BoundaryMode) (Engine, error) {
+		return NewHashLifeWorld(width, height, cells, rule)
+	},
+	"gpu": func(width, height, cells int, rule *LifeRule, boundary BoundaryMode) (Engine, error) {
+		g := NewGPUWorld(width, height, cells, rule)
+		g.Boundary = boundary
+		return g, nil
+	},
+}
+
+// engineNames returns the names of the engines in sorted order.
+func engineNames() []string {
+	names := make([]string, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkEngines runs every engine that works without a window for gens
+// generations and compares them cell by cell after each one, returning the
+// names of the engines it ran. They all start from the same soup of up to
+// cells live cells in a width×height rectangle.
+//
+// The engines with edges are first compared under every boundary mode. To
+// compare them with the unbounded engines as well, the soup is then put in
+// the middle of a world with dead edges that the soup can't reach within
+// gens generations, since no cell affects another more than one cell away
+// per generation. Unbounded engines can't run rules with B0, whose
+// background flashes forever, so those are left out for them.
+func checkEngines(rule *LifeRule, width, height, cells, gens int) ([]string, error) {
+	rng := newRand()
+	soup := make([]image.Point, cells)
+	for i := range soup {
+		soup[i] = image.Pt(rng.Intn(width), rng.Intn(height))
+	}
+	var bounded, all []string
+	for _, name := range engineNames() {
+		if name == "gpu" {
+			continue
+		}
+		e, err := engines[name](1, 1, 0, Conway, BoundaryDead)
+		if err != nil {
+			return nil, fmt.Errorf("engine %s: %v", name, err)
+		}
+		if _, ok := e.(viewSimulation); !ok {
+			bounded = append(bounded, name)
+			all = append(all, name)
+		} else if !rule.birth[0] {
+			all = append(all, name)
+		}
+	}
+
+	for _, boundary := range []BoundaryMode{BoundaryDead, BoundaryWrap, BoundaryMirror, BoundaryAlwaysAlive} {
+		if err := compareEngines(bounded, rule, boundary, width, height, image.Point{}, soup, gens); err != nil {
+			return nil, fmt.Errorf("%v boundary: %v", boundary, err)
+		}
+	}
+	margin := gens + 1
+	if err := compareEngines(all, rule, BoundaryDead, width+2*margin, height+2*margin, image.Pt(margin, margin), soup, gens); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// compareEngines runs the named engines on a width×height world, seeded
+// with the cells of soup moved by offset, and compares all their cells
+// after every one of gens generations.
+func compareEngines(names []string, rule *LifeRule, boundary BoundaryMode, width, height int, offset image.Point, soup []image.Point, gens int) error {
+	es := make([]Engine, len(names))
+	for i, name := range names {
+		e, err := engines[name](width, height, 0, rule, boundary)
+		if err != nil {
+			return fmt.Errorf("engine %s: %v", name, err)
+		}
+		for _, p := range soup {
+			p = p.Add(offset)
+			e.Set(p.X, p.Y, true)
+		}
+		es[i] = e
+	}
+	for gen := 1; gen <= gens; gen++ {
+		for _, e := range es {
+			t := time.Now()
+			e.Update(&t)
+		}
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				want := es[0].Alive(x, y)
+				for i, e := range es[1:] {
+					if e.Alive(x, y) != want {
+						return fmt.Errorf("generation %d: cell (%d, %d) is %v in engine %s but %v in engine %s",
+							gen, x, y, want, names[0], !want, names[i+1])
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
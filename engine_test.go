@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+// TestEnginesAgree runs every engine that works without a window from the
+// same soup and checks that they compute the same cells in every
+// generation, for rules with and without survival and with B0, under
+// every boundary mode the engines with edges support.
+func TestEnginesAgree(t *testing.T) {
+	rules := []string{
+		"B3/S23",
+		"B36/S23",
+		"B2/S",
+		// B0 without S8: the background flashes every generation.
+		"B03/S23",
+		// B0 with S8: the inverse of a rule without B0.
+		"B0123478/S01234678",
+	}
+	for _, s := range rules {
+		s := s
+		t.Run(s, func(t *testing.T) {
+			SetSeed(1)
+			rule := MustParseRule(s)
+			names, err := checkEngines(rule, 48, 32, 400, 24)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Only the GPU engine is left out, and for B0 rules the
+			// unbounded ones.
+			if len(names) < 2 || !rule.birth[0] && len(names) != len(engines)-1 {
+				t.Fatalf("only compared %v", names)
+			}
+			t.Logf("compared %v", names)
+		})
+	}
+}
+
+// TestGPUEngineAgrees would compare the GPU engine too, but it needs a
+// graphics context, which only exists while a window is open.
+func TestGPUEngineAgrees(t *testing.T) {
+	t.Skip("the gpu engine needs a graphics context, run -check-engines with a window instead")
+}
@@ -0,0 +1,115 @@
+package main
+
+import "sort"
+
+// cellChange records a cell coming to life or dying during Update.
+type cellChange struct {
+	i    int
+	born bool
+}
+
+// OnBirth registers f to be called during Update with the coordinates of
+// every cell that comes to life in it, once the new generation is
+// complete. Handlers are called on the goroutine running Update.
+func (w *World) OnBirth(f func(x, y int)) {
+	w.onBirth = append(w.onBirth, f)
+}
+
+// OnDeath registers f to be called during Update with the coordinates of
+// every cell that dies in it, like OnBirth.
+func (w *World) OnDeath(f func(x, y int)) {
+	w.onDeath = append(w.onDeath, f)
+}
+
+// Change is a cell that came to life or died in an Update.
+type Change struct {
+	X, Y int
+	Born bool
+}
+
+// subscription is a subscriber registered with Subscribe.
+type subscription struct {
+	f func(changes []Change)
+}
+
+// Subscribe registers f to be called after every Update with the cells
+// that were born or died in it, in row-major order. Cells that changed and
+// changed back within the update aren't included. The slice is reused, so
+// f must copy whatever it wants to keep. Calling the returned function
+// cancels the subscription; like Subscribe, it must be called on the
+// goroutine running Update.
+func (w *World) Subscribe(f func(changes []Change)) (cancel func()) {
+	s := &subscription{f}
+	w.subscribers = append(w.subscribers, s)
+	return func() {
+		for i, t := range w.subscribers {
+			if t == s {
+				w.subscribers = append(w.subscribers[:i], w.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// tracking reports whether Update has to record the cells that change.
+func (w *World) tracking() bool {
+	return len(w.onBirth) > 0 || len(w.onDeath) > 0 || len(w.subscribers) > 0
+}
+
+// recordChange notes that cell i is changing from state from to state to,
+// if that is a birth or death and anyone is interested.
+func (w *World) recordChange(i int, from, to State) {
+	if w.tracking() && live(from) != live(to) {
+		w.changes = append(w.changes, cellChange{i, to != Dead})
+	}
+}
+
+// recordChanges notes every birth and death between two whole generations.
+func (w *World) recordChanges(from, to []State) {
+	if !w.tracking() {
+		return
+	}
+	for i, s := range to {
+		w.recordChange(i, from[i], s)
+	}
+}
+
+// fireEvents calls the birth and death handlers for the changes recorded
+// during Update, then hands the net changes to the subscribers.
+func (w *World) fireEvents() {
+	for _, c := range w.changes {
+		handlers := w.onDeath
+		if c.born {
+			handlers = w.onBirth
+		}
+		for _, f := range handlers {
+			f(c.i%w.width, c.i/w.width)
+		}
+	}
+	if len(w.subscribers) == 0 {
+		return
+	}
+
+	// A cell recorded an odd number of times has changed for good, and
+	// its state before the update tells which way.
+	if len(w.flipped) != len(w.area) {
+		w.flipped = make([]bool, len(w.area))
+	}
+	for _, c := range w.changes {
+		w.flipped[c.i] = !w.flipped[c.i]
+	}
+	w.diff = w.diff[:0]
+	for _, c := range w.changes {
+		if w.flipped[c.i] {
+			w.flipped[c.i] = false
+			w.diff = append(w.diff, Change{c.i % w.width, c.i / w.width, w.prev[c.i] == Dead})
+		}
+	}
+	sort.Slice(w.diff, func(i, j int) bool {
+		a, b := w.diff[i], w.diff[j]
+		return a.Y < b.Y || a.Y == b.Y && a.X < b.X
+	})
+	for _, s := range w.subscribers {
+		s.f(w.diff)
+	}
+}
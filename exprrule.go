@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/antonmedv/expr"
+	"github.com/antonmedv/expr/vm"
+)
+
+// exprEnv are the variables an ExprRule expression can use.
+type exprEnv struct {
+	// State is the cell's current state.
+	State int `expr:"state"`
+	// Alive reports whether the cell is Alive.
+	Alive bool `expr:"alive"`
+	// N is the number of live neighbours.
+	N int `expr:"n"`
+}
+
+// exprKey identifies the inputs of one evaluation of an ExprRule.
+type exprKey struct {
+	self State
+	n    int
+}
+
+// ExprRule is a rule given as an expression, such as
+//
+//	alive ? n == 2 || n == 3 : n == 3
+//
+// over the variables state, alive and n, the number of live neighbours.
+// The expression evaluates either to a boolean, meaning Alive or Dead, or
+// to the next state as an integer. It is compiled once, and since it only
+// depends on its variables, each result is computed once and cached.
+type ExprRule struct {
+	src     string
+	program *vm.Program
+	cache   map[exprKey]State
+}
+
+// ParseExprRule compiles the rule expression src.
+func ParseExprRule(src string) (*ExprRule, error) {
+	program, err := expr.Compile(src, expr.Env(exprEnv{}))
+	if err != nil {
+		return nil, fmt.Errorf("rule expression %q: %v", src, err)
+	}
+	r := &ExprRule{src: src, program: program, cache: make(map[exprKey]State)}
+	// Evaluate once up front, so that expressions of the wrong type are
+	// reported before the world starts.
+	if _, err := r.eval(exprKey{}); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// eval runs the expression for key.
+func (r *ExprRule) eval(key exprKey) (State, error) {
+	out, err := expr.Run(r.program, exprEnv{State: int(key.self), Alive: key.self == Alive, N: key.n})
+	if err != nil {
+		return Dead, fmt.Errorf("rule expression %q: %v", r.src, err)
+	}
+	switch out := out.(type) {
+	case bool:
+		if out {
+			return Alive, nil
+		}
+		return Dead, nil
+	case int:
+		if out < 0 || out > 255 {
+			return Dead, fmt.Errorf("rule expression %q: state %d out of range", r.src, out)
+		}
+		return State(out), nil
+	}
+	return Dead, fmt.Errorf("rule expression %q: result %v is neither a boolean nor a state", r.src, out)
+}
+
+// Next implements Rule.
+func (r *ExprRule) Next(self State, neighbours []State) State {
+	key := exprKey{self: self}
+	for _, s := range neighbours {
+		if s == Alive {
+			key.n++
+		}
+	}
+	if next, ok := r.cache[key]; ok {
+		return next
+	}
+	next, err := r.eval(key)
+	if err != nil {
+		log.Print(err)
+	}
+	r.cache[key] = next
+	return next
+}
+
+// String returns the expression.
+func (r *ExprRule) String() string {
+	return r.src
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Forest-fire states.
+const (
+	FireEmpty   = Dead
+	FireTree    = Alive
+	FireBurning = Dying
+)
+
+// ForestFireParams are the probabilities driving the forest-fire model.
+type ForestFireParams struct {
+	// Growth is the probability that a tree grows on an empty cell.
+	Growth float64
+	// Lightning is the probability that a tree with no burning neighbour
+	// is struck and catches fire.
+	Lightning float64
+}
+
+// DefaultForestFireParams are parameters that give a steady state of fires
+// sweeping through a regrowing forest.
+var DefaultForestFireParams = ForestFireParams{Growth: 0.01, Lightning: 0.00005}
+
+// ParseForestFireParams parses a comma separated list of key=value pairs
+// such as "p=0.01,f=0.00005". Missing keys keep their default values.
+func ParseForestFireParams(s string) (ForestFireParams, error) {
+	p := DefaultForestFireParams
+	if strings.TrimSpace(s) == "" {
+		return p, nil
+	}
+	for _, f := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(f), "=", 2)
+		if len(kv) != 2 {
+			return p, fmt.Errorf("forest-fire parameters %q: expected key=value, got %q", s, f)
+		}
+		var err error
+		switch strings.ToLower(kv[0]) {
+		case "p":
+			p.Growth, err = strconv.ParseFloat(kv[1], 64)
+		case "f":
+			p.Lightning, err = strconv.ParseFloat(kv[1], 64)
+		default:
+			err = fmt.Errorf("unknown parameter")
+		}
+		if err != nil {
+			return p, fmt.Errorf("forest-fire parameters %q: %s: %v", s, f, err)
+		}
+	}
+	if p.Growth < 0 || p.Growth > 1 || p.Lightning < 0 || p.Lightning > 1 {
+		return p, fmt.Errorf("forest-fire parameters %q: probabilities must be between 0 and 1", s)
+	}
+	return p, nil
+}
+
+// ForestFireRule is the Drossel-Schwabl forest-fire model. A burning cell
+// burns down to an empty cell, a tree catches fire if a neighbour is
+// burning or, with probability Lightning, if it is struck, and a tree
+// grows on an empty cell with probability Growth.
+type ForestFireRule struct {
+	ForestFireParams
+	rng *rand.Rand
+}
+
+// NewForestFireRule returns a forest-fire rule with the given parameters,
+// drawing random numbers from rng.
+func NewForestFireRule(params ForestFireParams, rng *rand.Rand) *ForestFireRule {
+	return &ForestFireRule{ForestFireParams: params, rng: rng}
+}
+
+// States returns the number of cell states of the rule.
+func (r *ForestFireRule) States() int {
+	return 3
+}
+
+// Next implements Rule.
+func (r *ForestFireRule) Next(self State, neighbours []State) State {
+	switch self {
+	case FireBurning:
+		return FireEmpty
+	case FireTree:
+		for _, s := range neighbours {
+			if s == FireBurning {
+				return FireBurning
+			}
+		}
+		if r.rng.Float64() < r.Lightning {
+			return FireBurning
+		}
+		return FireTree
+	}
+	if r.rng.Float64() < r.Growth {
+		return FireTree
+	}
+	return FireEmpty
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GenerationsRule is a rule of the Generations family. It behaves like a
+// Life-like rule, except that a live cell which fails to survive doesn't die
+// at once but passes through a number of decay states. Decaying cells are
+// neither counted as neighbours nor can they be reborn until fully dead.
+type GenerationsRule struct {
+	LifeRule
+	// states is the total number of states including Dead and Alive.
+	states int
+}
+
+// ParseGenerationsRule parses a Generations rulestring in the "S/B/C" form
+// used by Golly, e.g. "345/2/4", or the equivalent "B2/S345/C4".
+func ParseGenerationsRule(s string) (*GenerationsRule, error) {
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("generations rule %q: expected three parts separated by '/'", s)
+	}
+	c := parts[2]
+	if hasPrefixFold(c, "C") || hasPrefixFold(c, "G") {
+		c = c[1:]
+	}
+	states, err := strconv.Atoi(c)
+	if err != nil || states < 2 || states > 256 {
+		return nil, fmt.Errorf("generations rule %q: state count must be between 2 and 256", s)
+	}
+	lr, err := ParseRule(parts[0] + "/" + parts[1])
+	if err != nil {
+		return nil, err
+	}
+	return &GenerationsRule{LifeRule: *lr, states: states}, nil
+}
+
+// MustParseGenerationsRule is like ParseGenerationsRule but panics if the
+// rulestring is invalid.
+func MustParseGenerationsRule(s string) *GenerationsRule {
+	r, err := ParseGenerationsRule(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// States returns the number of cell states of the rule.
+func (r *GenerationsRule) States() int {
+	return r.states
+}
+
+// Next implements Rule.
+func (r *GenerationsRule) Next(self State, neighbours []State) State {
+	switch {
+	case self == Dead:
+		return r.LifeRule.Next(self, neighbours)
+	case self == Alive:
+		if r.LifeRule.Next(self, neighbours) == Alive {
+			return Alive
+		}
+	}
+	if int(self)+1 >= r.states {
+		return Dead
+	}
+	return self + 1
+}
+
+// String returns the rule in S/B/C notation.
+func (r *GenerationsRule) String() string {
+	bs := r.LifeRule.String()
+	i := strings.Index(bs, "/")
+	return fmt.Sprintf("%s/%s/%d", bs[i+2:], bs[1:i], r.states)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	stdpalette "image/color/palette"
+	"image/gif"
+	"io"
+	"sort"
+	"time"
+)
+
+// gifPalettes are the ways of picking the colors of a GIF frame: fitted to
+// the colors of each frame, or one of the fixed palettes of the standard
+// library.
+var gifPalettes = map[string]color.Palette{
+	"adaptive": nil,
+	"plan9":    stdpalette.Plan9,
+	"websafe":  stdpalette.WebSafe,
+}
+
+// gifPaletteNames returns the names of gifPalettes in sorted order.
+func gifPaletteNames() []string {
+	names := make([]string, 0, len(gifPalettes))
+	for name := range gifPalettes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// gifEncoder is the clipEncoder of animated GIFs. Frames are quantized to
+// palette as they are added, or to the colors most used in them if it is
+// nil, and encoded all at once by write.
+type gifEncoder struct {
+	palette color.Palette
+	colors  int
+	anim    gif.GIF
+}
+
+// add implements clipEncoder.
+func (e *gifEncoder) add(img *image.RGBA, delay time.Duration) error {
+	pal := e.palette
+	if pal == nil {
+		pal = adaptivePalette(img, e.colors)
+	}
+	e.anim.Image = append(e.anim.Image, quantize(img, pal))
+	e.anim.Delay = append(e.anim.Delay, gifDelay(delay))
+	return nil
+}
+
+// write implements clipEncoder.
+func (e *gifEncoder) write(w io.Writer) error {
+	return gif.EncodeAll(w, &e.anim)
+}
+
+// gifDelay converts d to the centiseconds GIF delays are given in, taking
+// care not to round it to 0, which browsers replace with a much longer
+// delay.
+func gifDelay(d time.Duration) int {
+	return max(int((d+5*time.Millisecond)/(10*time.Millisecond)), 1)
+}
+
+// adaptivePalette returns the n colors of img used the most. Colors that
+// are the same to 5 bits per channel count as one, so that antialiased
+// edges don't crowd out colors that cover more of the frame, and the first
+// of them found stands for them all. Most simulations are drawn in a
+// handful of flat colors and fit into the palette exactly.
+func adaptivePalette(img *image.RGBA, n int) color.Palette {
+	counts := make(map[uint32]int)
+	first := make(map[uint32]color.RGBA)
+	for i := 0; i < len(img.Pix); i += 4 {
+		key := uint32(img.Pix[i]>>3)<<10 | uint32(img.Pix[i+1]>>3)<<5 | uint32(img.Pix[i+2]>>3)
+		if counts[key] == 0 {
+			first[key] = color.RGBA{img.Pix[i], img.Pix[i+1], img.Pix[i+2], 0xff}
+		}
+		counts[key]++
+	}
+	keys := make([]uint32, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return counts[keys[i]] > counts[keys[j]] })
+	colors := make([]color.RGBA, len(keys))
+	for i, key := range keys {
+		colors[i] = first[key]
+	}
+	if len(colors) > n {
+		colors = colors[:n]
+	}
+	pal := make(color.Palette, len(colors))
+	for i, c := range colors {
+		pal[i] = c
+	}
+	return pal
+}
+
+// quantize maps every pixel of img to the closest color in pal. Frames
+// repeat the same few colors over and over, so the closest color is only
+// looked up once for each of them.
+func quantize(img *image.RGBA, pal color.Palette) *image.Paletted {
+	dst := image.NewPaletted(img.Rect, pal)
+	index := make(map[uint32]uint8)
+	for i, j := 0, 0; i < len(img.Pix); i, j = i+4, j+1 {
+		key := uint32(img.Pix[i])<<16 | uint32(img.Pix[i+1])<<8 | uint32(img.Pix[i+2])
+		k, ok := index[key]
+		if !ok {
+			k = uint8(pal.Index(color.RGBA{img.Pix[i], img.Pix[i+1], img.Pix[i+2], 0xff}))
+			index[key] = k
+		}
+		dst.Pix[j] = k
+	}
+	return dst
+}
@@ -4,8 +4,11 @@ go 1.18
 
 require (
 	github.com/SHA65536/Hexago v0.0.0-20220608144557-97b8940f5f38
+	github.com/antonmedv/expr v1.12.0
 	github.com/fogleman/gg v1.3.0
 	github.com/hajimehoshi/ebiten/v2 v2.3.3
+	github.com/tetratelabs/wazero v1.3.0
+	github.com/yuin/gopher-lua v1.1.0
 )
 
 require (
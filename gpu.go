@@ -0,0 +1,245 @@
+package main
+
+import (
+	"image/color"
+	"time"
+
+	"github.com/fogleman/gg"
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// gpuLifeShader computes the next generation of a Life-like rule from the
+// current one in image 0, whose cells are opaque white when alive and
+// transparent when dead. Birth and Survival hold 1 for the neighbour
+// counts in the rule's sets, and Boundary is a BoundaryMode.
+const gpuLifeShader = `package main
+
+var Birth [9]float
+var Survival [9]float
+var Boundary float
+
+func alive(origin, texel, size, p vec2) float {
+	if Boundary == 1 {
+		p = mod(p, size)
+	} else if Boundary == 2 {
+		p = clamp(p, vec2(0.5), size-vec2(0.5))
+	} else if p.x < 0 || p.y < 0 || p.x > size.x || p.y > size.y {
+		if Boundary == 3 {
+			return 1
+		}
+		return 0
+	}
+	return imageSrc0UnsafeAt(origin + p*texel).a
+}
+
+func Fragment(position vec4, texCoord vec2, color vec4) vec4 {
+	texel := 1 / imageSrcTextureSize()
+	origin, size := imageSrcRegionOnTexture()
+	size /= texel
+	p := (texCoord - origin) / texel
+
+	n := 0.0
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			if dx != 0 || dy != 0 {
+				n += alive(origin, texel, size, p+vec2(float(dx), float(dy)))
+			}
+		}
+	}
+	self := imageSrc0UnsafeAt(texCoord).a
+	next := 0.0
+	for i := 0; i < 9; i++ {
+		if abs(n-float(i)) < 0.5 {
+			if self > 0.5 {
+				next = Survival[i]
+			} else {
+				next = Birth[i]
+			}
+		}
+	}
+	return vec4(next)
+}
+`
+
+// gpuMaxSteps bounds the generations a GPUWorld catches up on per frame.
+const gpuMaxSteps = 64
+
+// GPUWorld runs a Life-like rule on the Moore neighbourhood on the GPU:
+// each generation is a texture, computed from the previous one by a Kage
+// shader, and the cells only come back to the CPU when they are asked for.
+//
+// Ebiten must only be used from its own goroutine, so Update merely counts
+// the generations that are due, and DrawScreen computes them. Draw is the
+// only caller of DrawScreen, so that holding the renderer's read lock is
+// enough for it to change the world.
+type GPUWorld struct {
+	width, height int
+	rule          *LifeRule
+	// pending is the number of generations computed by the next
+	// DrawScreen.
+	pending    int
+	generation int
+	// seed is the initial soup, uploaded by the first DrawScreen.
+	seed      []byte
+	cur, next *ebiten.Image
+	shader    *ebiten.Shader
+	// population caches Population for populationGen.
+	population    int
+	populationGen int
+
+	// Boundary determines how cells beyond the edges of the world are
+	// resolved when counting neighbours.
+	Boundary BoundaryMode
+}
+
+// NewGPUWorld creates a width×height world running rule on the GPU, with up
+// to maxInitLiveCells random live cells.
+func NewGPUWorld(width, height, maxInitLiveCells int, rule *LifeRule) *GPUWorld {
+	g := &GPUWorld{
+		width:         width,
+		height:        height,
+		rule:          rule,
+		seed:          make([]byte, 4*width*height),
+		populationGen: -1,
+	}
+	rng := newRand()
+	for i := 0; i < maxInitLiveCells; i++ {
+		g.Set(rng.Intn(width), rng.Intn(height), true)
+	}
+	return g
+}
+
+// Alive reports whether the cell at (x, y) is alive. Cells outside the
+// world are dead. Once Ebiten is running, it computes the pending
+// generations and reads the cell back from the GPU.
+func (g *GPUWorld) Alive(x, y int) bool {
+	if x < 0 || y < 0 || x >= g.width || y >= g.height {
+		return false
+	}
+	if g.cur == nil {
+		return g.seed[4*(y*g.width+x)+3] != 0
+	}
+	g.step()
+	_, _, _, a := g.cur.At(x, y).RGBA()
+	return a >= 0x8000
+}
+
+// Set makes the cell at (x, y) alive or dead. Once Ebiten is running, it
+// computes the pending generations first, so the cell is set in the
+// current one.
+func (g *GPUWorld) Set(x, y int, alive bool) {
+	if x < 0 || y < 0 || x >= g.width || y >= g.height {
+		return
+	}
+	c := []byte{0, 0, 0, 0}
+	if alive {
+		c = []byte{0xff, 0xff, 0xff, 0xff}
+	}
+	if g.cur == nil {
+		copy(g.seed[4*(y*g.width+x):], c)
+		return
+	}
+	g.step()
+	g.cur.Set(x, y, color.RGBA{c[0], c[1], c[2], c[3]})
+}
+
+// Update implements Simulation by scheduling another generation.
+func (g *GPUWorld) Update(t *time.Time) {
+	g.pending++
+}
+
+// Draw implements Simulation. The cells live on the GPU and are drawn by
+// DrawScreen instead.
+func (g *GPUWorld) Draw(dc *gg.Context, p palette) {}
+
+// DrawScreen computes the pending generations and draws the live cells
+// over screen in the palette's Alive color, one pixel per cell transformed
+// by geo. It must be called from Ebiten's Draw.
+func (g *GPUWorld) DrawScreen(screen *ebiten.Image, p palette, geo ebiten.GeoM) {
+	g.setup()
+	g.step()
+
+	r, gr, b, _ := p[Alive].RGBA()
+	op := &ebiten.DrawImageOptions{GeoM: geo}
+	op.ColorM.Scale(float64(r)/0xffff, float64(gr)/0xffff, float64(b)/0xffff, 1)
+	screen.DrawImage(g.cur, op)
+}
+
+// setup creates the textures and the shader once Ebiten is running.
+func (g *GPUWorld) setup() {
+	if g.shader != nil {
+		return
+	}
+	shader, err := ebiten.NewShader([]byte(gpuLifeShader))
+	if err != nil {
+		panic(err)
+	}
+	g.shader = shader
+	g.cur = ebiten.NewImage(g.width, g.height)
+	g.next = ebiten.NewImage(g.width, g.height)
+	g.cur.ReplacePixels(g.seed)
+	g.seed = nil
+}
+
+// step runs the pending generations, up to gpuMaxSteps of them.
+func (g *GPUWorld) step() {
+	birth := make([]float32, mooreCounts)
+	survival := make([]float32, mooreCounts)
+	for n := range birth {
+		if g.rule.birth[n] {
+			birth[n] = 1
+		}
+		if g.rule.survival[n] {
+			survival[n] = 1
+		}
+	}
+	op := &ebiten.DrawRectShaderOptions{
+		CompositeMode: ebiten.CompositeModeCopy,
+		Uniforms: map[string]interface{}{
+			"Birth":    birth,
+			"Survival": survival,
+			"Boundary": float32(g.Boundary),
+		},
+	}
+	if g.pending > gpuMaxSteps {
+		g.pending = gpuMaxSteps
+	}
+	for ; g.pending > 0; g.pending-- {
+		op.Images[0] = g.cur
+		g.next.DrawRectShader(g.width, g.height, g.shader, op)
+		g.cur, g.next = g.next, g.cur
+		g.generation++
+	}
+}
+
+// Generation returns the number of generations computed so far.
+func (g *GPUWorld) Generation() int {
+	return g.generation
+}
+
+// Population returns the number of live cells. It reads the current
+// generation back from the GPU, once per generation, so like DrawScreen it
+// must be called from Ebiten's Draw.
+func (g *GPUWorld) Population() int {
+	if g.cur == nil || g.populationGen == g.generation {
+		return g.population
+	}
+	g.population = 0
+	for y := 0; y < g.height; y++ {
+		for x := 0; x < g.width; x++ {
+			if _, _, _, a := g.cur.At(x, y).RGBA(); a >= 0x8000 {
+				g.population++
+			}
+		}
+	}
+	g.populationGen = g.generation
+	return g.population
+}
+
+// screenSimulation is implemented by simulations that draw straight onto
+// the screen, after the rest of the frame has been drawn. geo maps cell
+// coordinates to screen coordinates.
+type screenSimulation interface {
+	Simulation
+	DrawScreen(screen *ebiten.Image, p palette, geo ebiten.GeoM)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	// graphGenerations is the number of generations the population graph
+	// spans, and graphWidth and graphHeight its size in pixels.
+	graphGenerations = 256
+	graphWidth       = 256
+	graphHeight      = 64
+)
+
+// populationGraph is the scrolling graph of the population of a world over
+// its last graphGenerations generations, in the bottom-left corner of the
+// screen.
+type populationGraph struct {
+	shown bool
+	// samples are the Stats of the world after every Update in the span of
+	// the graph, oldest first.
+	samples []Stats
+}
+
+// record adds the statistics of an Update to the graph, dropping those
+// that have scrolled out of it. A generation that doesn't follow the last
+// one, as after a reset or while time runs backwards, starts the graph
+// afresh.
+func (g *populationGraph) record(st Stats) {
+	if n := len(g.samples); n > 0 && g.samples[n-1].Generation >= st.Generation {
+		g.samples = g.samples[:0]
+	}
+	g.samples = append(g.samples, st)
+	drop := 0
+	for drop < len(g.samples) && g.samples[drop].Generation <= st.Generation-graphGenerations {
+		drop++
+	}
+	g.samples = append(g.samples[:0], g.samples[drop:]...)
+}
+
+// drawGraph draws the population graph, if it is shown, scaled so that the
+// highest population in its span reaches the top.
+func (r *Renderer) drawGraph(screen *ebiten.Image) {
+	g := &r.graph
+	if !g.shown || len(g.samples) == 0 {
+		return
+	}
+	rect := image.Rect(minimapMargin, r.screen.Y-minimapMargin-graphHeight, minimapMargin+graphWidth, r.screen.Y-minimapMargin)
+	ebitenutil.DrawRect(screen, float64(rect.Min.X), float64(rect.Min.Y), graphWidth, graphHeight, minimapBackground)
+
+	peak := 1
+	for _, st := range g.samples {
+		peak = max(peak, st.Population)
+	}
+	last := g.samples[len(g.samples)-1]
+	point := func(st Stats) (x, y float64) {
+		x = float64(rect.Max.X) - float64(last.Generation-st.Generation)*graphWidth/graphGenerations
+		y = float64(rect.Max.Y) - float64(st.Population)*(graphHeight-1)/float64(peak)
+		return x, y
+	}
+	for i := 1; i < len(g.samples); i++ {
+		x0, y0 := point(g.samples[i-1])
+		x1, y1 := point(g.samples[i])
+		ebitenutil.DrawLine(screen, x0, y0, x1, y1, r.theme.Live)
+	}
+	label := fmt.Sprintf("population %s, peak %s", groupDigits(last.Population), groupDigits(peak))
+	ebitenutil.DebugPrintAt(screen, label, rect.Min.X, rect.Min.Y-16)
+}
+
+// handleGraph shows and hides the population graph with the P key.
+func (r *Renderer) handleGraph() {
+	if _, ok := r.sim.(*World); ok && inpututil.IsKeyJustPressed(ebiten.KeyP) {
+		r.graph.shown = !r.graph.shown
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// Grid is the geometry of the cells of a world: how they are laid out,
+// which neighbourhoods make sense on them and how they are drawn.
+type Grid int
+
+const (
+	// SquareGrid has one square cell per screen pixel.
+	SquareGrid Grid = iota
+	// HexGrid is a Hexago grid of hexagonal cells.
+	HexGrid
+	// TriangleGrid is a lattice of alternately up- and down-pointing
+	// triangles.
+	TriangleGrid
+)
+
+var gridNames = map[Grid]string{
+	SquareGrid:   "square",
+	HexGrid:      "hex",
+	TriangleGrid: "tri",
+}
+
+func (g Grid) String() string {
+	if name, ok := gridNames[g]; ok {
+		return name
+	}
+	return fmt.Sprintf("Grid(%d)", int(g))
+}
+
+// ParseGrid returns the grid with the given name, as printed by
+// Grid.String.
+func ParseGrid(s string) (Grid, error) {
+	for g, name := range gridNames {
+		if name == s {
+			return g, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown grid %q", s)
+}
+
+// neighbourhood returns the neighbourhood rules run on the grid by default.
+func (g Grid) neighbourhood() Neighbourhood {
+	switch g {
+	case HexGrid:
+		return Hexagonal
+	case TriangleGrid:
+		return TriangleEdge
+	}
+	return Moore
+}
+
+// triangleSide is the length in pixels of the side of a triangular cell,
+// and triangleHeight its height.
+const triangleSide = 8
+
+var triangleHeight = triangleSide * math.Sqrt(3) / 2
+
+// size returns the number of columns and rows of a world on the grid
+// filling a screen of the given size.
+func (g Grid) size(screenWidth, screenHeight int) (width, height int) {
+	switch g {
+	case HexGrid:
+		return hexCols, hexRows
+	case TriangleGrid:
+		// Neighbouring triangles overlap by half a side.
+		return screenWidth/(triangleSide/2) - 1, int(float64(screenHeight) / triangleHeight)
+	}
+	return screenWidth, screenHeight
+}
+
+// Grid returns the geometry of the world's cells, which follows from its
+// neighbourhood.
+func (w *World) Grid() Grid {
+	return w.Neighbourhood.grid()
+}
+
+// triangleCorners returns the corners in pixels of the triangular cell at
+// (x, y), which points up if x+y is even and down otherwise.
+func triangleCorners(x, y int) [3][2]float64 {
+	const half = triangleSide / 2
+	left, mid, right := float64(x*half), float64((x+1)*half), float64((x+2)*half)
+	top, bottom := float64(y)*triangleHeight, float64(y+1)*triangleHeight
+	if (x+y)&1 == 0 {
+		return [3][2]float64{{left, bottom}, {right, bottom}, {mid, top}}
+	}
+	return [3][2]float64{{left, top}, {right, top}, {mid, bottom}}
+}
+
+// drawTriangles paints every cell that isn't dead as a filled triangle.
+func (w *World) drawTriangles(dc *gg.Context, p palette) {
+	w.ForEachIn(w.bounds, func(x, y int, v State) {
+		if v == Dead {
+			return
+		}
+		t := triangleCorners(x, y)
+		dc.MoveTo(t[0][0], t[0][1])
+		dc.LineTo(t[1][0], t[1][1])
+		dc.LineTo(t[2][0], t[2][1])
+		dc.ClosePath()
+		dc.SetColor(p.color(v))
+		dc.Fill()
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// gridLinesMinCell is the size in pixels cells must be drawn at on the
+// screen before grid lines are drawn between them; below it the lines
+// would bury the cells.
+const gridLinesMinCell = 4
+
+// cellSpacing returns the distance between cell boundaries in world
+// coordinates, for simulations drawn on a square grid. ok is false for
+// worlds on other grids: hexagonal worlds outline every hexagon anyway.
+func (r *Renderer) cellSpacing() (spacing float64, ok bool) {
+	switch s := r.sim.(type) {
+	case *World:
+		return 1, s.Grid() == SquareGrid
+	case *SandpileWorld:
+		return float64(s.scale), true
+	case *LeniaWorld:
+		return float64(s.scale), true
+	}
+	return 1, true
+}
+
+// drawGridLines outlines the cells on screen in the theme's grid color if
+// grid lines are on and the camera is zoomed in far enough, so that edits
+// land on the intended cell. Only the world itself is covered, unless the
+// simulation is unbounded.
+func (r *Renderer) drawGridLines(screen *ebiten.Image) {
+	spacing, ok := r.cellSpacing()
+	if !r.gridLines || !ok || spacing*r.camera.Zoom < gridLinesMinCell {
+		return
+	}
+	sw, sh := screen.Size()
+	x0, y0 := r.camera.ScreenToWorld(0, 0)
+	x1, y1 := r.camera.ScreenToWorld(float64(sw), float64(sh))
+	if _, view := r.sim.(viewSimulation); !view {
+		x0, y0 = math.Max(x0, 0), math.Max(y0, 0)
+		x1, y1 = math.Min(x1, float64(r.dc.Width())), math.Min(y1, float64(r.dc.Height()))
+	}
+	left, top := r.camera.WorldToScreen(x0, y0)
+	right, bottom := r.camera.WorldToScreen(x1, y1)
+	for x := math.Ceil(x0/spacing) * spacing; x <= x1; x += spacing {
+		sx, _ := r.camera.WorldToScreen(x, 0)
+		ebitenutil.DrawRect(screen, math.Floor(sx), top, 1, bottom-top, r.theme.Grid)
+	}
+	for y := math.Ceil(y0/spacing) * spacing; y <= y1; y += spacing {
+		_, sy := r.camera.WorldToScreen(0, y)
+		ebitenutil.DrawRect(screen, left, math.Floor(sy), right-left, 1, r.theme.Grid)
+	}
+}
+
+// handleGridLines toggles the grid lines with the G key.
+func (r *Renderer) handleGridLines() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyG) {
+		r.gridLines = !r.gridLines
+	}
+}
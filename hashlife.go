@@ -0,0 +1,389 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"time"
+
+	"github.com/fogleman/gg"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// hlNode is a square of 2^level × 2^level cells of a HashLife universe.
+// Nodes are hash-consed: there is only one node for every distinct square,
+// so equal subpatterns are shared and their futures computed only once.
+type hlNode struct {
+	nw, ne, sw, se *hlNode
+	level          uint8
+	population     int64
+}
+
+type hlKey struct {
+	nw, ne, sw, se *hlNode
+}
+
+type hlResultKey struct {
+	n *hlNode
+	j uint8
+}
+
+// hashLifeMaxNodes is the number of nodes after which the caches are
+// flushed, keeping only what the current universe needs.
+const hashLifeMaxNodes = 1 << 22
+
+// HashLifeWorld runs a Life-like rule with Gosper's HashLife algorithm. The
+// universe is an unbounded quadtree of hash-consed nodes whose futures are
+// memoized, which makes it possible to skip ahead by huge numbers of
+// generations in one go for patterns with regular structure.
+type HashLifeWorld struct {
+	rule    *LifeRule
+	nodes   map[hlKey]*hlNode
+	results map[hlResultKey]*hlNode
+	empties []*hlNode
+	dead    *hlNode
+	alive   *hlNode
+	// root is centred on the origin: it covers the cells from -2^(level-1)
+	// to 2^(level-1)-1 on both axes.
+	root       *hlNode
+	generation int64
+
+	// Step is the number of generations each tick advances.
+	Step int64
+}
+
+// NewHashLifeWorld creates a HashLife universe running rule, seeded with up
+// to maxInitLiveCells random live cells within the rectangle from the
+// origin to (width, height). Rules with B0 aren't supported.
+func NewHashLifeWorld(width, height, maxInitLiveCells int, rule *LifeRule) (*HashLifeWorld, error) {
+	if rule.birth[0] {
+		return nil, fmt.Errorf("hashlife: rule %v has B0", rule)
+	}
+	h := &HashLifeWorld{
+		rule:    rule,
+		nodes:   make(map[hlKey]*hlNode),
+		results: make(map[hlResultKey]*hlNode),
+		dead:    &hlNode{},
+		alive:   &hlNode{population: 1},
+		Step:    1,
+	}
+	h.root = h.empty(3)
+	rng := newRand()
+	for i := 0; i < maxInitLiveCells; i++ {
+		h.Set(rng.Intn(width), rng.Intn(height), true)
+	}
+	return h, nil
+}
+
+// join returns the node with the given quadrants.
+func (h *HashLifeWorld) join(nw, ne, sw, se *hlNode) *hlNode {
+	k := hlKey{nw, ne, sw, se}
+	if n, ok := h.nodes[k]; ok {
+		return n
+	}
+	n := &hlNode{
+		nw: nw, ne: ne, sw: sw, se: se,
+		level:      nw.level + 1,
+		population: nw.population + ne.population + sw.population + se.population,
+	}
+	h.nodes[k] = n
+	return n
+}
+
+// empty returns the empty node of the given level.
+func (h *HashLifeWorld) empty(level uint8) *hlNode {
+	if len(h.empties) == 0 {
+		h.empties = append(h.empties, h.dead)
+	}
+	for int(level) >= len(h.empties) {
+		e := h.empties[len(h.empties)-1]
+		h.empties = append(h.empties, h.join(e, e, e, e))
+	}
+	return h.empties[level]
+}
+
+// expand returns a node one level up with n in its centre.
+func (h *HashLifeWorld) expand(n *hlNode) *hlNode {
+	e := h.empty(n.level - 1)
+	return h.join(
+		h.join(e, e, e, n.nw),
+		h.join(e, e, n.ne, e),
+		h.join(e, n.sw, e, e),
+		h.join(n.se, e, e, e),
+	)
+}
+
+// centre returns the node one level down covering the centre of n.
+func (h *HashLifeWorld) centre(n *hlNode) *hlNode {
+	return h.join(n.nw.se, n.ne.sw, n.sw.ne, n.se.nw)
+}
+
+// crop shrinks the root as long as all its live cells lie in its centre,
+// keeping it at level 3 at least.
+func (h *HashLifeWorld) crop() {
+	for h.root.level > 3 {
+		c := h.centre(h.root)
+		if c.population != h.root.population {
+			return
+		}
+		h.root = c
+	}
+}
+
+// Alive reports whether the cell at (x, y) is alive.
+func (h *HashLifeWorld) Alive(x, y int) bool {
+	n := h.root
+	half := int64(1) << (n.level - 1)
+	cx, cy := int64(x)+half, int64(y)+half
+	if cx < 0 || cy < 0 || cx >= 2*half || cy >= 2*half {
+		return false
+	}
+	for n.level > 0 && n.population > 0 {
+		half := int64(1) << (n.level - 1)
+		switch {
+		case cx < half && cy < half:
+			n = n.nw
+		case cy < half:
+			n, cx = n.ne, cx-half
+		case cx < half:
+			n, cy = n.sw, cy-half
+		default:
+			n, cx, cy = n.se, cx-half, cy-half
+		}
+	}
+	return n == h.alive
+}
+
+// Set makes the cell at (x, y) alive or dead.
+func (h *HashLifeWorld) Set(cellX, cellY int, alive bool) {
+	x, y := int64(cellX), int64(cellY)
+	for {
+		half := int64(1) << (h.root.level - 1)
+		if -half <= x && x < half && -half <= y && y < half {
+			v := h.dead
+			if alive {
+				v = h.alive
+			}
+			h.root = h.set(h.root, x+half, y+half, v)
+			return
+		}
+		h.root = h.expand(h.root)
+	}
+}
+
+// set returns n with the cell at (x, y), relative to its top-left corner,
+// replaced by the leaf v.
+func (h *HashLifeWorld) set(n *hlNode, x, y int64, v *hlNode) *hlNode {
+	if n.level == 0 {
+		return v
+	}
+	half := int64(1) << (n.level - 1)
+	nw, ne, sw, se := n.nw, n.ne, n.sw, n.se
+	switch {
+	case x < half && y < half:
+		nw = h.set(nw, x, y, v)
+	case y < half:
+		ne = h.set(ne, x-half, y, v)
+	case x < half:
+		sw = h.set(sw, x, y-half, v)
+	default:
+		se = h.set(se, x-half, y-half, v)
+	}
+	return h.join(nw, ne, sw, se)
+}
+
+// leaf returns the leaf node for a cell state.
+func (h *HashLifeWorld) leaf(alive bool) *hlNode {
+	if alive {
+		return h.alive
+	}
+	return h.dead
+}
+
+// step4x4 computes the centre 2×2 cells of a level 2 node one generation
+// ahead.
+func (h *HashLifeWorld) step4x4(n *hlNode) *hlNode {
+	var cells [4][4]bool
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			q := [4]*hlNode{n.nw, n.ne, n.sw, n.se}[y/2*2+x/2]
+			l := [4]*hlNode{q.nw, q.ne, q.sw, q.se}[y%2*2+x%2]
+			cells[y][x] = l == h.alive
+		}
+	}
+	next := func(x, y int) *hlNode {
+		count := 0
+		for _, o := range mooreOffsets {
+			if cells[y+o[1]][x+o[0]] {
+				count++
+			}
+		}
+		return h.leaf(h.rule.nextAlive(cells[y][x], count))
+	}
+	return h.join(next(1, 1), next(2, 1), next(1, 2), next(2, 2))
+}
+
+// successor returns the centre of n, one level down, 2^j generations
+// ahead. j must be at most n.level-2.
+func (h *HashLifeWorld) successor(n *hlNode, j uint8) *hlNode {
+	if n.population == 0 {
+		return h.empty(n.level - 1)
+	}
+	k := hlResultKey{n, j}
+	if r, ok := h.results[k]; ok {
+		return r
+	}
+	var r *hlNode
+	if n.level == 2 {
+		r = h.step4x4(n)
+	} else {
+		// The nine overlapping subsquares of half the size, each advanced
+		// by up to half the generations.
+		c1 := h.successor(h.join(n.nw.nw, n.nw.ne, n.nw.sw, n.nw.se), j)
+		c2 := h.successor(h.join(n.nw.ne, n.ne.nw, n.nw.se, n.ne.sw), j)
+		c3 := h.successor(h.join(n.ne.nw, n.ne.ne, n.ne.sw, n.ne.se), j)
+		c4 := h.successor(h.join(n.nw.sw, n.nw.se, n.sw.nw, n.sw.ne), j)
+		c5 := h.successor(h.join(n.nw.se, n.ne.sw, n.sw.ne, n.se.nw), j)
+		c6 := h.successor(h.join(n.ne.sw, n.ne.se, n.se.nw, n.se.ne), j)
+		c7 := h.successor(h.join(n.sw.nw, n.sw.ne, n.sw.sw, n.sw.se), j)
+		c8 := h.successor(h.join(n.sw.ne, n.se.nw, n.sw.se, n.se.sw), j)
+		c9 := h.successor(h.join(n.se.nw, n.se.ne, n.se.sw, n.se.se), j)
+		if j < n.level-2 {
+			// The nine results are already 2^j generations ahead; only
+			// their centres need to be assembled.
+			r = h.join(
+				h.join(c1.se, c2.sw, c4.ne, c5.nw),
+				h.join(c2.se, c3.sw, c5.ne, c6.nw),
+				h.join(c4.se, c5.sw, c7.ne, c8.nw),
+				h.join(c5.se, c6.sw, c8.ne, c9.nw),
+			)
+		} else {
+			r = h.join(
+				h.successor(h.join(c1, c2, c4, c5), j),
+				h.successor(h.join(c2, c3, c5, c6), j),
+				h.successor(h.join(c4, c5, c7, c8), j),
+				h.successor(h.join(c5, c6, c8, c9), j),
+			)
+		}
+	}
+	h.results[k] = r
+	return r
+}
+
+// advancePow advances the universe by 2^j generations. The root is first
+// padded so that the pattern can't grow out of the centre that successor
+// returns.
+func (h *HashLifeWorld) advancePow(j uint8) {
+	for h.root.level < j+1 {
+		h.root = h.expand(h.root)
+	}
+	h.root = h.successor(h.expand(h.expand(h.root)), j)
+	h.crop()
+}
+
+// Advance moves the universe n generations ahead, in one successor step
+// per set bit of n.
+func (h *HashLifeWorld) Advance(n int64) {
+	for j := uint8(0); n>>j != 0; j++ {
+		if n>>j&1 != 0 {
+			h.advancePow(j)
+		}
+	}
+	h.generation += n
+	if len(h.nodes) > hashLifeMaxNodes {
+		h.flush()
+	}
+}
+
+// flush drops all cached nodes and results, then re-registers the nodes of
+// the current universe.
+func (h *HashLifeWorld) flush() {
+	h.nodes = make(map[hlKey]*hlNode)
+	h.results = make(map[hlResultKey]*hlNode)
+	h.empties = nil
+	var intern func(n *hlNode)
+	intern = func(n *hlNode) {
+		if n.level == 0 {
+			return
+		}
+		k := hlKey{n.nw, n.ne, n.sw, n.se}
+		if _, ok := h.nodes[k]; ok {
+			return
+		}
+		intern(n.nw)
+		intern(n.ne)
+		intern(n.sw)
+		intern(n.se)
+		h.nodes[k] = n
+	}
+	intern(h.root)
+}
+
+// Update advances the universe by Step generations.
+func (h *HashLifeWorld) Update(t *time.Time) {
+	h.Advance(h.Step)
+}
+
+// Generation returns the number of generations run so far.
+func (h *HashLifeWorld) Generation() int64 {
+	return h.generation
+}
+
+// Population returns the number of live cells.
+func (h *HashLifeWorld) Population() int64 {
+	return h.root.population
+}
+
+// Draw paints the live cells in the screen-sized window at the origin.
+func (h *HashLifeWorld) Draw(dc *gg.Context, p palette) {
+	h.DrawView(dc, p, image.Rect(0, 0, dc.Width(), dc.Height()))
+}
+
+// DrawView implements viewSimulation, descending only into the nodes that
+// overlap view and have live cells.
+func (h *HashLifeWorld) DrawView(dc *gg.Context, p palette, view image.Rectangle) {
+	dc.SetColor(p.color(Alive))
+	half := int64(1) << (h.root.level - 1)
+	h.draw(dc, h.root, -half, -half, view)
+}
+
+func (h *HashLifeWorld) draw(dc *gg.Context, n *hlNode, x, y int64, view image.Rectangle) {
+	size := int64(1) << n.level
+	if n.population == 0 ||
+		x >= int64(view.Max.X) || y >= int64(view.Max.Y) ||
+		x+size <= int64(view.Min.X) || y+size <= int64(view.Min.Y) {
+		return
+	}
+	if n.level == 0 {
+		dc.SetPixel(int(x)-view.Min.X, int(y)-view.Min.Y)
+		return
+	}
+	half := size / 2
+	h.draw(dc, n.nw, x, y, view)
+	h.draw(dc, n.ne, x+half, y, view)
+	h.draw(dc, n.sw, x, y+half, view)
+	h.draw(dc, n.se, x+half, y+half, view)
+}
+
+// handleHashLifeStep doubles and halves the number of generations per tick
+// of a HashLife world with the Page Up and Page Down keys.
+func (r *Renderer) handleHashLifeStep() {
+	h, ok := r.sim.(*HashLifeWorld)
+	if !ok {
+		return
+	}
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyPageUp):
+		r.queueEdit(func() {
+			if h.Step < 1<<60 {
+				h.Step *= 2
+			}
+		})
+	case inpututil.IsKeyJustPressed(ebiten.KeyPageDown):
+		r.queueEdit(func() {
+			if h.Step > 1 {
+				h.Step /= 2
+			}
+		})
+	}
+}
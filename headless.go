@@ -0,0 +1,9 @@
+//go:build ebitencbackend
+
+package main
+
+// headless is set in builds made with -tags ebitencbackend, which use
+// Ebiten's C backend rather than GLFW. They start without a display, so
+// they can run the modes that need no window on a server, but can't open
+// one.
+const headless = true
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// heatmap counts how often every cell changed state over a sliding window
+// of generations.
+type heatmap struct {
+	window int
+	counts []uint16
+	// ring holds the cells that changed in each of the last window
+	// generations, the oldest at next, so their changes can be taken off
+	// the counts again once they leave the window.
+	ring [][]int32
+	next int
+}
+
+// reset forgets every change.
+func (h *heatmap) reset() {
+	*h = heatmap{}
+}
+
+// updateHeat records which cells changed state in the generation just
+// computed, given the states before it, if HeatWindow is set.
+func (w *World) updateHeat(prev []State) {
+	h := &w.heat
+	if w.HeatWindow <= 0 {
+		h.reset()
+		return
+	}
+	if h.window != w.HeatWindow || len(h.counts) != len(w.area) {
+		*h = heatmap{
+			window: w.HeatWindow,
+			counts: make([]uint16, len(w.area)),
+			ring:   make([][]int32, w.HeatWindow),
+		}
+	}
+	for _, i := range h.ring[h.next] {
+		h.counts[i]--
+	}
+	changed := h.ring[h.next][:0]
+	for i, s := range w.area {
+		if s != prev[i] {
+			h.counts[i]++
+			changed = append(changed, int32(i))
+		}
+	}
+	h.ring[h.next] = changed
+	h.next = (h.next + 1) % h.window
+}
+
+// Heat returns the fraction of the last HeatWindow generations in which
+// the cell at (x, y) changed state. It is 0 for cells outside the world and
+// while HeatWindow is unset.
+func (w *World) Heat(x, y int) float64 {
+	if x < 0 || y < 0 || x >= w.width || y >= w.height || w.heat.counts == nil {
+		return 0
+	}
+	return float64(w.heat.counts[y*w.width+x]) / float64(w.heat.window)
+}
+
+// heatStops are the colors of the heat ramp, from cells that hardly ever
+// change to cells that change every generation.
+var heatStops = []color.RGBA{
+	{0x00, 0x00, 0x00, 0x00},
+	{0x20, 0x30, 0xc0, 0xff},
+	{0xe0, 0x20, 0x20, 0xff},
+	{0xff, 0xe0, 0x20, 0xff},
+	{0xff, 0xff, 0xff, 0xff},
+}
+
+// heatRamp is heatStops interpolated into 256 colors.
+var heatRamp = func() (ramp [256]color.RGBA) {
+	segments := len(heatStops) - 1
+	for i := range ramp {
+		pos := i * segments
+		j, f := pos/0xff, uint32(pos%0xff*256/0xff)
+		if j == segments {
+			j, f = segments-1, 256
+		}
+		a, b := heatStops[j], heatStops[j+1]
+		ramp[i] = color.RGBA{fade(b.R, a.R, f), fade(b.G, a.G, f), fade(b.B, a.B, f), fade(b.A, a.A, f)}
+	}
+	return ramp
+}()
+
+// DrawHeatPixels is the heatmap view of DrawPixels: every cell that changed
+// state in the last HeatWindow generations, dead or alive, is colored along
+// heatRamp by how often it did, and the others show the background. Only
+// square grids without zones are drawn this way.
+func (w *World) DrawHeatPixels(img *image.RGBA, p palette) bool {
+	if w.Grid() != SquareGrid || w.zones != nil {
+		return false
+	}
+	h := &w.heat
+	if h.counts == nil {
+		return w.DrawPixels(img, p)
+	}
+	b := img.Rect.Intersect(image.Rect(0, 0, w.width, w.height))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		counts := h.counts[y*w.width : (y+1)*w.width]
+		pix := img.Pix[img.PixOffset(0, y):]
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if n := int(counts[x]); n > 0 {
+				c := heatRamp[n*0xff/h.window]
+				pix[4*x], pix[4*x+1], pix[4*x+2], pix[4*x+3] = c.R, c.G, c.B, c.A
+			}
+		}
+	}
+	w.drawFlagPixels(img)
+	return true
+}
+
+// handleHeatmap toggles the heatmap view with the H key. The world only
+// starts counting changes the first time the heatmap is shown, over the
+// last heatWindow generations.
+func (r *Renderer) handleHeatmap() {
+	w, ok := r.sim.(*World)
+	if !ok || !inpututil.IsKeyJustPressed(ebiten.KeyH) {
+		return
+	}
+	r.heatmap = !r.heatmap
+	r.redraw()
+	if r.heatmap && w.HeatWindow <= 0 {
+		window := r.heatWindow
+		r.queueEdit(func() { w.HeatWindow = window })
+	}
+}
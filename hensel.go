@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HenselRule is an isotropic non-totalistic rule written in Hensel notation,
+// such as "B2-a/S12". Besides the number of live neighbours, each count can
+// be restricted to particular arrangements of those neighbours, named by a
+// letter. Since the rule is isotropic, arrangements that are rotations or
+// reflections of each other share a letter.
+type HenselRule struct {
+	// birth and survival are indexed by the neighbourhood bit mask, whose
+	// bit i is the i-th Moore neighbour in mooreOffsets; see neighbourMask.
+	birth    [256]bool
+	survival [256]bool
+	name     string
+}
+
+// henselLetters lists, for each neighbour count up to four, the letters in
+// their conventional order together with one representative arrangement.
+// The arrangements are given as 3×3 pictures in reading order with the
+// center skipped, '#' marking a live neighbour. Counts five to seven use the
+// complements of the arrangements for 8-n.
+var henselLetters = [5][]struct {
+	letter byte
+	cells  string
+}{
+	1: {{'c', "#.. .. ..."}, {'e', ".#. .. ..."}},
+	2: {
+		{'c', "#.# .. ..."}, {'e', ".#. #. ..."}, {'k', ".#. .. #.."},
+		{'a', "##. .. ..."}, {'i', ".#. .. .#."}, {'n', "#.. .. ..#"},
+	},
+	3: {
+		{'c', "#.# .. ..#"}, {'e', ".#. ## ..."}, {'k', "#.. .# .#."},
+		{'a', "##. #. ..."}, {'i', "### .. ..."}, {'n', "##. .. ..#"},
+		{'y', "#.# .. .#."}, {'q', "#.# #. ..."}, {'j', "##. .. .#."},
+		{'r', "##. .# ..."},
+	},
+	4: {
+		{'c', "#.# .. #.#"}, {'e', ".#. ## .#."}, {'k', "#.# #. ..#"},
+		{'a', "### #. ..."}, {'i', ".#. ## #.."}, {'n', "#.# ## ..."},
+		{'y', "#.# #. .#."}, {'q', "##. #. ..#"}, {'j', "### .. ..#"},
+		{'r', "##. ## ..."}, {'t', "### .. .#."}, {'w', "##. .# ..#"},
+		{'z', "##. .. .##"},
+	},
+}
+
+// henselTable maps the canonical form of every neighbourhood mask to its
+// letter; see canonicalMask.
+var henselTable = buildHenselTable()
+
+func buildHenselTable() map[uint8]byte {
+	t := make(map[uint8]byte)
+	for n := 1; n <= 7; n++ {
+		src, invert := n, false
+		if n > 4 {
+			src, invert = 8-n, true
+		}
+		for _, l := range henselLetters[src] {
+			var m uint8
+			for i, c := range strings.ReplaceAll(l.cells, " ", "") {
+				if c == '#' {
+					m |= 1 << i
+				}
+			}
+			if invert {
+				m = ^m
+			}
+			t[canonicalMask(m)] = l.letter
+		}
+	}
+	return t
+}
+
+// canonicalMask returns the smallest mask among all rotations and
+// reflections of the neighbourhood m.
+func canonicalMask(m uint8) uint8 {
+	min := m
+	for k := 1; k < 8; k++ {
+		var t uint8
+		for i, o := range mooreOffsets {
+			if m&(1<<i) == 0 {
+				continue
+			}
+			x, y := o[0], o[1]
+			if k&4 != 0 {
+				x = -x
+			}
+			for r := 0; r < k&3; r++ {
+				x, y = -y, x
+			}
+			for j, o2 := range mooreOffsets {
+				if o2 == [2]int{x, y} {
+					t |= 1 << j
+				}
+			}
+		}
+		if t < min {
+			min = t
+		}
+	}
+	return min
+}
+
+// ParseHenselRule parses an isotropic non-totalistic rulestring in Hensel
+// notation, e.g. "B2-a/S12" or "B2ce3/S23-q". A count followed by letters
+// allows only those arrangements, and a count followed by '-' and letters
+// allows all but those.
+func ParseHenselRule(s string) (*HenselRule, error) {
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if len(parts) != 2 || !hasPrefixFold(parts[0], "B") || !hasPrefixFold(parts[1], "S") {
+		return nil, fmt.Errorf("hensel rule %q: expected B.../S...", s)
+	}
+	r := &HenselRule{name: s}
+	if err := parseHenselCounts(&r.birth, parts[0][1:]); err != nil {
+		return nil, fmt.Errorf("hensel rule %q: birth: %v", s, err)
+	}
+	if err := parseHenselCounts(&r.survival, parts[1][1:]); err != nil {
+		return nil, fmt.Errorf("hensel rule %q: survival: %v", s, err)
+	}
+	return r, nil
+}
+
+// parseHenselCounts marks every neighbourhood mask allowed by spec.
+func parseHenselCounts(set *[256]bool, spec string) error {
+	for len(spec) > 0 {
+		c := spec[0]
+		if c < '0' || c > '8' {
+			return fmt.Errorf("invalid neighbour count %q", c)
+		}
+		n := int(c - '0')
+		spec = spec[1:]
+		exclude := strings.HasPrefix(spec, "-")
+		if exclude {
+			spec = spec[1:]
+		}
+		i := strings.IndexAny(spec, "012345678")
+		if i < 0 {
+			i = len(spec)
+		}
+		letters := spec[:i]
+		spec = spec[i:]
+		if exclude && letters == "" {
+			return fmt.Errorf("'-' after %d must be followed by letters", n)
+		}
+
+		valid := map[byte]bool{}
+		if src := min(n, 8-n); src > 0 {
+			for _, l := range henselLetters[src] {
+				valid[l.letter] = true
+			}
+		}
+		for j := 0; j < len(letters); j++ {
+			if !valid[letters[j]] {
+				return fmt.Errorf("invalid letter %q for %d neighbours", letters[j], n)
+			}
+		}
+
+		for m := 0; m < 256; m++ {
+			if bitCount(uint8(m)) != n {
+				continue
+			}
+			ok := letters == ""
+			if !ok {
+				ok = strings.IndexByte(letters, henselTable[canonicalMask(uint8(m))]) >= 0
+				ok = ok != exclude
+			}
+			if ok {
+				set[m] = true
+			}
+		}
+	}
+	return nil
+}
+
+func bitCount(m uint8) int {
+	n := 0
+	for ; m != 0; m &= m - 1 {
+		n++
+	}
+	return n
+}
+
+// neighbourMask packs the live neighbours of a Moore neighbourhood, as
+// gathered by neighbourStates, into a bit mask.
+func neighbourMask(neighbours []State) uint8 {
+	var m uint8
+	for i, s := range neighbours {
+		if s == Alive && i < 8 {
+			m |= 1 << i
+		}
+	}
+	return m
+}
+
+// Next implements Rule.
+func (r *HenselRule) Next(self State, neighbours []State) State {
+	m := neighbourMask(neighbours)
+	if self == Alive && r.survival[m] || self != Alive && r.birth[m] {
+		return Alive
+	}
+	return Dead
+}
+
+// String returns the rulestring the rule was parsed from.
+func (r *HenselRule) String() string {
+	return r.name
+}
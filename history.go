@@ -0,0 +1,171 @@
+package main
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	// historyDepth is the default number of generations in the history
+	// stack.
+	historyDepth = 32
+	// historyHeight is the height of the whole stack as a fraction of the
+	// screen, and historyFloor the opacity of its oldest layer.
+	historyHeight = 0.4
+	historyFloor  = 0.1
+)
+
+// historyStack is the isometric view of the last generations of a world,
+// stacked on top of each other with the oldest at the bottom, so that
+// patterns trace out their course through time.
+type historyStack struct {
+	shown bool
+	depth int
+	// layers is a ring of the cells of the last generations, the oldest at
+	// next once it is full, and gens the generation of each layer.
+	layers [][]State
+	gens   []int
+	next   int
+	width  int
+	// images are the layers drawn in the colors of theme, and uploaded the
+	// generation each of them shows.
+	images   []*ebiten.Image
+	uploaded []int
+	theme    *Theme
+}
+
+// record adds the current generation of w to the stack, if it is shown. A
+// generation that doesn't follow the last one, as after a reset or while
+// time runs backwards, or a world of a different size, starts the stack
+// afresh.
+func (h *historyStack) record(w *World) {
+	if !h.shown || h.depth < 1 || w.Grid() != SquareGrid {
+		return
+	}
+	if n := len(h.layers); n > 0 {
+		last := (h.next + n - 1) % n
+		if h.gens[last] >= w.Generation() || len(h.layers[last]) != len(w.area) || h.width != w.width {
+			h.layers, h.gens, h.next = h.layers[:0], h.gens[:0], 0
+		}
+	}
+	h.width = w.width
+	if len(h.layers) < h.depth {
+		h.layers = append(h.layers, append([]State(nil), w.area...))
+		h.gens = append(h.gens, w.Generation())
+		return
+	}
+	h.layers[h.next] = append(h.layers[h.next][:0], w.area...)
+	h.gens[h.next] = w.Generation()
+	h.next = (h.next + 1) % len(h.layers)
+}
+
+// upload brings the image of every layer up to date with its cells in the
+// colors of p, leaving dead cells transparent so the layers underneath
+// show through.
+func (h *historyStack) upload(p palette, theme *Theme) {
+	if h.theme != theme {
+		h.uploaded = h.uploaded[:0]
+		h.theme = theme
+	}
+	colors := p.rgbaTable()
+	var pix []byte
+	for i, cells := range h.layers {
+		height := len(cells) / h.width
+		if i == len(h.images) {
+			h.images = append(h.images, nil)
+		}
+		if i == len(h.uploaded) {
+			h.uploaded = append(h.uploaded, -1)
+		}
+		if w, hh := sizeOf(h.images[i]); w != h.width || hh != height {
+			h.images[i] = resizedImage(h.images[i], h.width, height)
+			h.uploaded[i] = -1
+		}
+		if h.uploaded[i] == h.gens[i] {
+			continue
+		}
+		if len(pix) != 4*len(cells) {
+			pix = make([]byte, 4*len(cells))
+		}
+		for j, s := range cells {
+			c := colors[s]
+			if s == Dead {
+				c.R, c.G, c.B, c.A = 0, 0, 0, 0
+			}
+			pix[4*j], pix[4*j+1], pix[4*j+2], pix[4*j+3] = c.R, c.G, c.B, c.A
+		}
+		h.images[i].ReplacePixels(pix)
+		h.uploaded[i] = h.gens[i]
+	}
+}
+
+// sizeOf returns the size of img, or zero if it is nil.
+func sizeOf(img *ebiten.Image) (int, int) {
+	if img == nil {
+		return 0, 0
+	}
+	return img.Size()
+}
+
+// drawHistory draws the history stack of w over screen in place of the
+// world: every layer is turned by 45 degrees and squashed to half its
+// height, the usual 2:1 isometric projection, and raised above the one
+// before it. Older layers are fainter, and the stack is fitted to the
+// screen.
+func (r *Renderer) drawHistory(screen *ebiten.Image, w *World, p palette) {
+	h := &r.history
+	h.upload(p, r.theme)
+	n := len(h.layers)
+	if n == 0 {
+		return
+	}
+	width, height := float64(w.width), float64(w.height)
+	sw, sh := float64(r.screen.X), float64(r.screen.Y)
+	rise := historyHeight * sh / float64(max(h.depth-1, 1))
+	// The projected layer is (width+height)/√2 wide and half as high.
+	diag := (width + height) / math.Sqrt2
+	zoom := math.Min(0.9*sw/diag, 0.9*(sh-rise*float64(n-1))/(diag/2))
+
+	op := &ebiten.DrawImageOptions{}
+	for i := 0; i < n; i++ {
+		// Oldest first, so that newer layers are drawn over it.
+		slot := (h.next + i) % n
+		op.GeoM.Reset()
+		op.GeoM.Translate(-width/2, -height/2)
+		op.GeoM.Rotate(math.Pi / 4)
+		op.GeoM.Scale(zoom, zoom/2)
+		op.GeoM.Translate(sw/2, sh/2+rise*float64(n-1)/2-rise*float64(i))
+		op.ColorM.Reset()
+		alpha := 1.0
+		if n > 1 {
+			alpha = historyFloor + (1-historyFloor)*float64(i)/float64(n-1)
+		}
+		op.ColorM.Scale(1, 1, 1, alpha)
+		screen.DrawImage(h.images[slot], op)
+	}
+}
+
+// historyShown reports whether the history stack replaces the world on the
+// screen.
+func (r *Renderer) historyShown() bool {
+	w, ok := r.sim.(*World)
+	return ok && r.history.shown && w.Grid() == SquareGrid
+}
+
+// handleHistory toggles the history stack with the Y key. It starts out
+// empty and fills up as the world runs.
+func (r *Renderer) handleHistory() {
+	if !inpututil.IsKeyJustPressed(ebiten.KeyY) {
+		return
+	}
+	r.queueEdit(func() {
+		h := &r.history
+		h.shown = !h.shown
+		h.layers, h.gens, h.next = h.layers[:0], h.gens[:0], 0
+		if w, ok := r.sim.(*World); ok && h.shown {
+			h.record(w)
+		}
+	})
+}
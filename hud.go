@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// hudLines returns the lines of the HUD: what the simulation is up to, how
+// to control it, and how fast the renderer runs.
+func (r *Renderer) hudLines() []string {
+	var hud []string
+	if g, ok := r.sim.(*GPUWorld); ok {
+		hud = append(hud, fmt.Sprintf("generation %d on the GPU", g.Generation()))
+	}
+	if h, ok := r.sim.(*HashLifeWorld); ok {
+		hud = append(hud, fmt.Sprintf("generation %d, %d live cells, %d generations per tick (PgUp/PgDn)", h.Generation(), h.Population(), h.Step))
+	}
+	if s, ok := r.sim.(*SparseWorld); ok {
+		hud = append(hud, fmt.Sprintf("camera at %.0f,%.0f, %d live cells, drag or arrows to pan, wheel to zoom", r.camera.X, r.camera.Y, s.Population()))
+	}
+	if w, ok := r.sim.(*World); ok && r.Editing() {
+		hud = append(hud, r.editHelp(w))
+	} else if w, ok := r.sim.(*World); ok {
+		st := w.Stats()
+		hud = append(hud, fmt.Sprintf("%s, %d born, %d died, density %.3f, %v per tick", w.Summary(), st.Births, st.Deaths, st.Density, w.TickDuration().Round(time.Microsecond)))
+		if mr, ok := w.rule.(multiColorRule); ok {
+			hud = append(hud, fmt.Sprint("population by color:", w.Populations(mr.Colors() + 1)[Alive:]))
+		}
+		if br, ok := w.rule.(*BlockRule); ok && br.Reversible() {
+			if w.Reverse {
+				hud = append(hud, "time reversed, R to run forwards")
+			} else {
+				hud = append(hud, "R to reverse time")
+			}
+		}
+		hud = append(hud, fmt.Sprint("rule: ", w.Rule()))
+		if w.Metrics {
+			hud = append(hud, fmt.Sprintf("entropy %.3f bits, clustering %.3f", st.Entropy, st.Clustering))
+		}
+		if p := w.Period(); p > 0 {
+			hud = append(hud, fmt.Sprintf("cycling with period %d", p))
+		}
+	}
+	if r.Paused() {
+		hud = append(hud, "paused, Space to resume")
+	}
+	hud = append(hud, timingSummary())
+	if s := r.recordingSummary(); s != "" {
+		hud = append(hud, s)
+	}
+	if s := r.videoSummary(); s != "" {
+		hud = append(hud, s)
+	}
+	return hud
+}
+
+// drawHUD prints the HUD in the top-left corner of the screen, unless it
+// has been hidden with F1.
+func (r *Renderer) drawHUD(screen *ebiten.Image) {
+	if r.hudHidden {
+		return
+	}
+	ebitenutil.DebugPrint(screen, strings.Join(r.hudLines(), "\n"))
+}
+
+// handleHUD shows and hides the HUD with the F1 key.
+func (r *Renderer) handleHUD() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyF1) {
+		r.hudHidden = !r.hudHidden
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+// neighbourCounts keeps the number of live Moore neighbours of every cell
+// from one generation to the next, adjusting it only around the cells that
+// were born or died.
+type neighbourCounts struct {
+	counts []uint8
+	// counted is the generation counts describe. Cells edited since then
+	// are found by comparing it to the world.
+	counted  []State
+	boundary BoundaryMode
+}
+
+// updateIncremental advances a Life-like rule on the Moore neighbourhood,
+// reading neighbour counts from w.counter instead of gathering the
+// neighbours of every cell.
+func (w *World) updateIncremental(rule *LifeRule) {
+	c := &w.counter
+	if len(c.counted) != len(w.area) || c.boundary != w.Boundary {
+		w.recount()
+	} else {
+		w.adjustCounts(c.counted, w.area)
+	}
+
+	next := w.nextBuffer()
+	for i, self := range w.area {
+		next[i] = Dead
+		if rule.nextAlive(self == Alive, int(c.counts[i])) {
+			next[i] = Alive
+		}
+		w.countChange(self, next[i])
+	}
+	w.adjustCounts(w.area, next)
+	w.recordChanges(w.area, next)
+	copy(c.counted, next)
+	w.swap()
+}
+
+// recount counts the live neighbours of every cell from scratch.
+func (w *World) recount() {
+	c := &w.counter
+	c.counts = make([]uint8, len(w.area))
+	c.counted = append(c.counted[:0], w.area...)
+	c.boundary = w.Boundary
+	var neighbours []State
+	for y := 0; y < w.height; y++ {
+		for x := 0; x < w.width; x++ {
+			neighbours = neighbourStates(neighbours[:0], w.area, w.width, w.height, x, y, w.Boundary, Moore)
+			for _, s := range neighbours {
+				if s == Alive {
+					c.counts[y*w.width+x]++
+				}
+			}
+		}
+	}
+}
+
+// adjustCounts updates the neighbour counts for every cell that is alive in
+// from but not in to, or the other way around.
+func (w *World) adjustCounts(from, to []State) {
+	for i, s := range to {
+		if (s == Alive) == (from[i] == Alive) {
+			continue
+		}
+		delta := uint8(1)
+		if s != Alive {
+			delta = 0xff // wraps around to subtract one
+		}
+		w.adjustAround(i%w.width, i/w.width, delta)
+	}
+}
+
+// adjustAround adds delta to the count of every cell that has (x, y) among
+// its Moore neighbours. Away from the edges those are just the eight cells
+// around it; at the edges the boundary mode may make a cell a neighbour of
+// cells on the far side, or a neighbour twice over.
+func (w *World) adjustAround(x, y int, delta uint8) {
+	width, height := w.width, w.height
+	counts := w.counter.counts
+	if x > 0 && y > 0 && x < width-1 && y < height-1 {
+		for _, o := range mooreOffsets {
+			counts[(y-o[1])*width+x-o[0]] += delta
+		}
+		return
+	}
+	for _, o := range mooreOffsets {
+		for _, x2 := range preimages(x, o[0], width) {
+			for _, y2 := range preimages(y, o[1], height) {
+				x3, y3, ok, _ := w.Boundary.resolve(width, height, x2+o[0], y2+o[1])
+				if ok && x3 == x && y3 == y {
+					counts[y2*width+x2] += delta
+				}
+			}
+		}
+	}
+}
+
+// preimages returns the coordinates inside [0, n) that, moved by d of at
+// most one and resolved by any boundary mode, could land on i: the plain
+// one, the ones wrapped around, and the ones mirrored back in.
+func preimages(i, d, n int) []int {
+	var out []int
+	for _, j := range [...]int{i - d, i - d + n, i - d - n, -1 - i - d, 2*n - 1 - i - d} {
+		if j < 0 || j >= n {
+			continue
+		}
+		dup := false
+		for _, k := range out {
+			dup = dup || k == j
+		}
+		if !dup {
+			out = append(out, j)
+		}
+	}
+	return out
+}
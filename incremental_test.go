@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+// benchmarkCounting runs Conway's Life on the same soup either gathering
+// the neighbours of every cell or keeping their counts incrementally.
+func benchmarkCounting(b *testing.B, incremental bool) {
+	SetSeed(1)
+	w := NewWorld(512, 512, 512*512/3)
+	w.Workers = 1
+	w.IncrementalCounts = incremental
+	// Let the soup settle a little, and the counts be counted.
+	w.Step(10)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.Step(1)
+	}
+}
+
+func BenchmarkGatherNeighbours(b *testing.B) {
+	benchmarkCounting(b, false)
+}
+
+func BenchmarkIncrementalCounts(b *testing.B) {
+	benchmarkCounting(b, true)
+}
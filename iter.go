@@ -0,0 +1,25 @@
+package main
+
+import "image"
+
+// ForEachLive calls f with the coordinates of every cell that isn't dead, in
+// row-major order. Only the cells within Bounds are visited.
+func (w *World) ForEachLive(f func(x, y int)) {
+	w.ForEachIn(w.bounds, func(x, y int, s State) {
+		if s != Dead {
+			f(x, y)
+		}
+	})
+}
+
+// ForEachIn calls f with the coordinates and state of every cell of r that
+// lies within the world, dead ones included, in row-major order.
+func (w *World) ForEachIn(r image.Rectangle, f func(x, y int, s State)) {
+	r = r.Intersect(image.Rect(0, 0, w.width, w.height))
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := w.area[y*w.width : (y+1)*w.width]
+		for x := r.Min.X; x < r.Max.X; x++ {
+			f(x, y, row[x])
+		}
+	}
+}
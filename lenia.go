@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+// LeniaParams are the parameters of a Lenia world.
+type LeniaParams struct {
+	// R is the kernel radius in cells.
+	R int
+	// T is the number of integration steps per unit of time; each tick
+	// advances the world by 1/T.
+	T int
+	// Mu and Sigma are the center and width of the growth function.
+	Mu, Sigma float64
+}
+
+// DefaultLeniaParams are the parameters of Orbium, the best known Lenia
+// glider.
+var DefaultLeniaParams = LeniaParams{R: 13, T: 10, Mu: 0.15, Sigma: 0.015}
+
+// ParseLeniaParams parses parameters written as comma-separated key=value
+// pairs, e.g. "R=13,T=10,mu=0.15,sigma=0.015". Missing keys keep their
+// default values.
+func ParseLeniaParams(s string) (LeniaParams, error) {
+	p := DefaultLeniaParams
+	if strings.TrimSpace(s) == "" {
+		return p, nil
+	}
+	for _, f := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(f), "=", 2)
+		if len(kv) != 2 {
+			return p, fmt.Errorf("lenia parameters %q: expected key=value, got %q", s, f)
+		}
+		var err error
+		switch strings.ToLower(kv[0]) {
+		case "r":
+			p.R, err = strconv.Atoi(kv[1])
+		case "t":
+			p.T, err = strconv.Atoi(kv[1])
+		case "mu":
+			p.Mu, err = strconv.ParseFloat(kv[1], 64)
+		case "sigma":
+			p.Sigma, err = strconv.ParseFloat(kv[1], 64)
+		default:
+			err = fmt.Errorf("unknown parameter")
+		}
+		if err != nil {
+			return p, fmt.Errorf("lenia parameters %q: %s: %v", s, f, err)
+		}
+	}
+	if p.R < 1 || p.T < 1 || p.Sigma <= 0 {
+		return p, fmt.Errorf("lenia parameters %q: R and T must be positive and sigma greater than zero", s)
+	}
+	return p, nil
+}
+
+// leniaTap is one non-zero entry of the convolution kernel.
+type leniaTap struct {
+	dx, dy int
+	weight float64
+}
+
+// LeniaWorld is a continuous cellular automaton: every cell holds a value
+// between 0 and 1, the neighbourhood is a smooth ring-shaped kernel, and
+// cells grow or shrink by a smooth function of their weighted neighbourhood
+// sum. The world is always toroidal.
+type LeniaWorld struct {
+	cells  []float64
+	next   []float64
+	width  int
+	height int
+	// scale is the size in pixels a cell is drawn at.
+	scale  int
+	params LeniaParams
+	kernel []leniaTap
+	// padded holds the cells with R cells of wrapped border on every side,
+	// so the convolution never has to wrap coordinates itself.
+	padded []float64
+	rng    *rand.Rand
+}
+
+// NewLeniaWorld creates a Lenia world covering a screenWidth×screenHeight
+// area with cells of scale×scale pixels, seeded with a few random patches.
+func NewLeniaWorld(screenWidth, screenHeight, scale int, params LeniaParams) *LeniaWorld {
+	width, height := screenWidth/scale, screenHeight/scale
+	l := &LeniaWorld{
+		cells:  make([]float64, width*height),
+		next:   make([]float64, width*height),
+		width:  width,
+		height: height,
+		scale:  scale,
+		params: params,
+		kernel: leniaKernel(params.R),
+		padded: make([]float64, (width+2*params.R)*(height+2*params.R)),
+		rng:    newRand(),
+	}
+	l.init()
+	return l
+}
+
+// leniaKernel returns the taps of the standard single-ring kernel of radius
+// r, normalized to sum to one.
+func leniaKernel(r int) []leniaTap {
+	var taps []leniaTap
+	var sum float64
+	for dy := -r; dy <= r; dy++ {
+		for dx := -r; dx <= r; dx++ {
+			d := math.Sqrt(float64(dx*dx+dy*dy)) / float64(r)
+			if d == 0 || d >= 1 {
+				continue
+			}
+			// Exponential bump peaking at half the radius.
+			w := math.Exp(4 - 1/(d*(1-d)))
+			taps = append(taps, leniaTap{dx, dy, w})
+			sum += w
+		}
+	}
+	for i := range taps {
+		taps[i].weight /= sum
+	}
+	return taps
+}
+
+// init scatters random square patches about two kernel radii wide.
+func (l *LeniaWorld) init() {
+	size := 2 * l.params.R
+	for n := 0; n < 6; n++ {
+		x0, y0 := l.rng.Intn(l.width), l.rng.Intn(l.height)
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				i := (y0+y)%l.height*l.width + (x0+x)%l.width
+				l.cells[i] = l.rng.Float64()
+			}
+		}
+	}
+}
+
+// growth maps a neighbourhood sum u to a growth rate in [-1, 1].
+func (l *LeniaWorld) growth(u float64) float64 {
+	d := (u - l.params.Mu) / l.params.Sigma
+	return 2*math.Exp(-d*d/2) - 1
+}
+
+// Update integrates the world by one time step of 1/T.
+func (l *LeniaWorld) Update(t *time.Time) {
+	width, height, r := l.width, l.height, l.params.R
+	pw := width + 2*r
+	for py := 0; py < height+2*r; py++ {
+		y := ((py-r)%height + height) % height
+		for px := 0; px < pw; px++ {
+			x := ((px-r)%width + width) % width
+			l.padded[py*pw+px] = l.cells[y*width+x]
+		}
+	}
+
+	offsets := make([]int, len(l.kernel))
+	for i, k := range l.kernel {
+		offsets[i] = k.dy*pw + k.dx
+	}
+
+	dt := 1 / float64(l.params.T)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := (y+r)*pw + x + r
+			var u float64
+			for i, k := range l.kernel {
+				u += k.weight * l.padded[c+offsets[i]]
+			}
+			v := l.cells[y*width+x] + dt*l.growth(u)
+			l.next[y*width+x] = math.Max(0, math.Min(1, v))
+		}
+	}
+	l.cells, l.next = l.next, l.cells
+}
+
+// leniaGradient are the stops of the color ramp cell values are drawn with,
+// from 0 to 1.
+var leniaGradient = []color.RGBA{
+	{0x00, 0x00, 0x00, 0x00},
+	{0x20, 0x10, 0x60, 0xff},
+	{0x20, 0x80, 0xc0, 0xff},
+	{0x60, 0xe0, 0x80, 0xff},
+	{0xff, 0xff, 0x60, 0xff},
+}
+
+// gradientColor interpolates linearly between the gradient stops.
+func gradientColor(stops []color.RGBA, v float64) color.RGBA {
+	f := v * float64(len(stops)-1)
+	i := int(f)
+	if i >= len(stops)-1 {
+		return stops[len(stops)-1]
+	}
+	a, b, t := stops[i], stops[i+1], f-float64(i)
+	lerp := func(x, y uint8) uint8 {
+		return uint8(float64(x) + t*(float64(y)-float64(x)))
+	}
+	return color.RGBA{lerp(a.R, b.R), lerp(a.G, b.G), lerp(a.B, b.B), lerp(a.A, b.A)}
+}
+
+// Draw renders the cell values with a smooth color gradient. The palette is
+// ignored, since Lenia has no discrete states. Cells are written straight
+// into the context's pixel buffer, which is much faster than filling one
+// rectangle per cell.
+func (l *LeniaWorld) Draw(dc *gg.Context, p palette) {
+	img, ok := dc.Image().(*image.RGBA)
+	if !ok {
+		return
+	}
+	for y := 0; y < l.height; y++ {
+		for x := 0; x < l.width; x++ {
+			v := l.cells[y*l.width+x]
+			if v < 1.0/256 {
+				continue
+			}
+			c := gradientColor(leniaGradient, v)
+			for py := y * l.scale; py < (y+1)*l.scale; py++ {
+				for px := x * l.scale; px < (x+1)*l.scale; px++ {
+					img.SetRGBA(px, py, c)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// countingRule is implemented by totalistic rules that only depend on the
+// number of live cells in a square neighbourhood of some radius. World.Update
+// counts those with a sliding window sum instead of gathering every
+// neighbour state, which keeps large radii affordable.
+type countingRule interface {
+	Rule
+	// Radius returns the radius of the Moore neighbourhood.
+	Radius() int
+	// NextCount returns the next state of a cell given its state and the
+	// number of live cells around it, not counting the cell itself.
+	NextCount(self State, count int) State
+}
+
+// LtLRule is a Larger than Life rule: a Life-like rule over a Moore
+// neighbourhood of arbitrary radius, where birth and survival are given as
+// ranges of live neighbour counts.
+type LtLRule struct {
+	radius int
+	// states is the number of cell states. Rules with more than two states
+	// decay like Generations rules.
+	states int
+	// middle reports whether the cell itself is included in its count.
+	middle     bool
+	sMin, sMax int
+	bMin, bMax int
+}
+
+// Bugs is the best known Larger than Life rule, named for the small
+// gliders it supports.
+var Bugs = MustParseLtLRule("R5,C0,M1,S34..58,B34..45,NM")
+
+// maxRadius is the largest radius of a Larger than Life rule.
+const maxRadius = 500
+
+// ParseLtLRule parses a Larger than Life rulestring in the notation used by
+// Golly, such as "R5,C0,M1,S34..58,B34..45,NM". Only the Moore neighbourhood
+// (NM) is supported.
+func ParseLtLRule(s string) (*LtLRule, error) {
+	r := &LtLRule{radius: 1, states: 2}
+	for _, f := range strings.Split(strings.TrimSpace(s), ",") {
+		if f == "" {
+			return nil, fmt.Errorf("ltl rule %q: empty field", s)
+		}
+		key, val := strings.ToUpper(f[:1]), f[1:]
+		var err error
+		switch key {
+		case "R":
+			r.radius, err = strconv.Atoi(val)
+			if err == nil && (r.radius < 1 || r.radius > maxRadius) {
+				err = fmt.Errorf("radius out of range")
+			}
+		case "C":
+			r.states, err = strconv.Atoi(val)
+			if r.states == 0 {
+				r.states = 2
+			}
+			if err == nil && (r.states < 2 || r.states > 256) {
+				err = fmt.Errorf("state count out of range")
+			}
+		case "M":
+			switch val {
+			case "0":
+			case "1":
+				r.middle = true
+			default:
+				err = fmt.Errorf("middle must be 0 or 1")
+			}
+		case "S":
+			r.sMin, r.sMax, err = parseRange(val)
+		case "B":
+			r.bMin, r.bMax, err = parseRange(val)
+		case "N":
+			if !strings.EqualFold(val, "M") {
+				err = fmt.Errorf("unsupported neighbourhood %q", val)
+			}
+		default:
+			err = fmt.Errorf("unknown field")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ltl rule %q: %s: %v", s, f, err)
+		}
+	}
+	return r, nil
+}
+
+// MustParseLtLRule is like ParseLtLRule but panics if the rulestring is
+// invalid.
+func MustParseLtLRule(s string) *LtLRule {
+	r, err := ParseLtLRule(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// parseRange parses "min..max" or a single count.
+func parseRange(s string) (min, max int, err error) {
+	lo, hi := s, s
+	if i := strings.Index(s, ".."); i >= 0 {
+		lo, hi = s[:i], s[i+2:]
+	}
+	if min, err = strconv.Atoi(lo); err != nil {
+		return 0, 0, err
+	}
+	if max, err = strconv.Atoi(hi); err != nil {
+		return 0, 0, err
+	}
+	if min > max {
+		return 0, 0, fmt.Errorf("empty range %s", s)
+	}
+	return min, max, nil
+}
+
+// Radius implements countingRule.
+func (r *LtLRule) Radius() int {
+	return r.radius
+}
+
+// States returns the number of cell states of the rule.
+func (r *LtLRule) States() int {
+	return r.states
+}
+
+// Next implements Rule by counting the live cells among neighbours.
+func (r *LtLRule) Next(self State, neighbours []State) State {
+	n := 0
+	for _, s := range neighbours {
+		if s == Alive {
+			n++
+		}
+	}
+	return r.NextCount(self, n)
+}
+
+// NextCount implements countingRule.
+func (r *LtLRule) NextCount(self State, count int) State {
+	if r.middle && self == Alive {
+		count++
+	}
+	switch self {
+	case Dead:
+		if r.bMin <= count && count <= r.bMax {
+			return Alive
+		}
+		return Dead
+	case Alive:
+		if r.sMin <= count && count <= r.sMax {
+			return Alive
+		}
+	}
+	if int(self)+1 >= r.states {
+		return Dead
+	}
+	return self + 1
+}
+
+// String returns the rule in Golly's Larger than Life notation.
+func (r *LtLRule) String() string {
+	states := r.states
+	if states == 2 {
+		states = 0
+	}
+	middle := 0
+	if r.middle {
+		middle = 1
+	}
+	return fmt.Sprintf("R%d,C%d,M%d,S%d..%d,B%d..%d,NM", r.radius, states, middle, r.sMin, r.sMax, r.bMin, r.bMax)
+}
+
+// updateCounting advances the world by one tick under a counting rule.
+func (w *World) updateCounting(rule countingRule) {
+	counts := w.boxCounts(rule.Radius())
+	next := w.nextBuffer()
+	for i, self := range w.area {
+		n := counts[i]
+		if self == Alive {
+			n--
+		}
+		next[i] = rule.NextCount(self, int(n))
+		w.countChange(self, next[i])
+	}
+	w.recordChanges(w.area, next)
+	w.swap()
+}
+
+// boxCounts returns, for every cell, the number of live cells in the square
+// of the given radius centred on it, the cell itself included. Cells beyond
+// the edges are resolved by the boundary mode. The squares are summed with
+// a sliding window, first along each row and then down the columns, so
+// every count costs a constant number of additions regardless of the
+// radius. The counts are only valid until the next call.
+func (w *World) boxCounts(rad int) []int32 {
+	width, height := w.width, w.height
+	d := 2 * rad
+
+	// rows[py*width+x] is the number of live cells in padded row py between
+	// padded columns x and x+d, that is world columns x-rad to x+rad.
+	rows := zeroed(&w.scratch.rows, width*(height+d))
+	live := zeroed(&w.scratch.live, width+d)
+	for py := 0; py < height+d; py++ {
+		for px := range live {
+			live[px] = 0
+			x2, y2, ok, alive := w.Boundary.resolve(width, height, px-rad, py-rad)
+			if ok && w.area[y2*width+x2] == Alive || !ok && alive {
+				live[px] = 1
+			}
+		}
+		var sum int32
+		for px := 0; px < d; px++ {
+			sum += live[px]
+		}
+		row := rows[py*width : (py+1)*width]
+		for x := range row {
+			sum += live[x+d]
+			row[x] = sum
+			sum -= live[x]
+		}
+	}
+
+	counts := zeroed(&w.scratch.counts, width*height)
+	window := zeroed(&w.scratch.window, width)
+	for py := 0; py < d; py++ {
+		for x, n := range rows[py*width : (py+1)*width] {
+			window[x] += n
+		}
+	}
+	for y := 0; y < height; y++ {
+		in := rows[(y+d)*width : (y+d+1)*width]
+		out := rows[y*width : (y+1)*width]
+		c := counts[y*width : (y+1)*width]
+		for x := range window {
+			window[x] += in[x]
+			c[x] = window[x]
+			window[x] -= out[x]
+		}
+	}
+	return counts
+}
@@ -25,10 +25,17 @@ package main
 
 import (
 	"errors"
+	"flag"
 	"fmt"
+	"image"
+	"image/color"
 	"log"
+	"math"
 	"math/rand"
 	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -37,69 +44,560 @@ import (
 	"github.com/hajimehoshi/ebiten/v2"
 )
 
-func init() {
-	rand.Seed(time.Now().UnixNano())
+var (
+	flagRule          = flag.String("rule", "B3/S23", "rulestring in B/S, Hensel, Generations, Larger than Life or weighted notation (e.g. B36/S23, B2/S34H, B2-a/S12, 345/2/4, R5,C0,M1,S34..58,B34..45,NM, W1,2,1;2,0,2;1,2,1/B5,6/S3..6) or one of: "+strings.Join(presetNames(), ", "))
+	flagBoundary      = flag.String("boundary", "dead", "what lies beyond the world edges: dead, wrap, mirror or alive")
+	flagNeighbourhood = flag.String("neighbourhood", "", "neighbourhood the rule is evaluated over: moore, vonneumann, extended, hex, tri3 or tri12; defaults to the one named by the rule")
+	flagEngine        = flag.String("engine", "parallel", "backend computing the generations: naive or parallel run every kind of rule, on one goroutine updating every cell or on -workers of them skipping the unchanged parts; bitset, sparse, hashlife and gpu only Life-like rules on the Moore neighbourhood. One of: "+strings.Join(engineNames(), ", "))
+	flagCheckEngines  = flag.Int("check-engines", 0, "run every engine that works without a window for this many generations from the same soup of the Life-like -rule, compare them cell by cell and exit")
+	flagHashLife      = flag.Int64("hashlife", 0, "run an unbounded HashLife universe advancing this many generations per tick, viewed like -sparse; -engine hashlife advances one")
+	flagSparse        = flag.Bool("sparse", false, "run an unbounded world that only stores its live cells, viewed through a camera panned with the arrow keys; same as -engine sparse")
+	flagGPU           = flag.Bool("gpu", false, "compute every generation on the GPU with a shader; same as -engine gpu")
+	flagPacked        = flag.Bool("packed", false, "store the world as a bitset, one bit per cell; same as -engine bitset")
+	flagGrid          = flag.String("grid", "", "cell geometry: square, hex or tri; defaults to the one the neighbourhood is defined on")
+	flagWorkers       = flag.Int("workers", runtime.NumCPU(), "number of goroutines each generation is computed on")
+	flagIncremental   = flag.Bool("incremental", false, "keep the neighbour counts of Life-like rules on the Moore neighbourhood across generations, adjusting them around the cells that changed")
+	flagPauseOnCycle  = flag.Bool("pause-on-cycle", false, "pause as soon as the world repeats a recent generation; press Space to resume")
+	flagOnSettle      = flag.String("on-settle", "continue", "what to do once the world dies out or settles into a still life or period 2 oscillation: continue, pause, reseed or exit")
+	flagBench         = flag.Int("bench", 0, "run this many ticks as fast as possible without a window, then print how fast they ran and how much memory they used; build with -tags ebitencbackend to run it where there is no display, as on CI machines")
+	flagMetrics       = flag.Bool("metrics", false, "compute the entropy of 3x3 neighbourhoods and the clustering coefficient of live cells every generation")
+	flagSeed          = flag.Int64("seed", 0, "seed for the random number generators, to reproduce a run; 0 picks one from the clock")
+	flagCellSize      = flag.Int("cell-size", 1, "size in pixels of a cell, so that the world is this many times smaller than the screen")
+	flagTheme         = flag.String("theme", defaultTheme, "color theme, one of "+strings.Join(themeNames(), ", ")+"; T cycles through them at runtime")
+	flagAgeColors     = flag.Bool("age-colors", false, "fade live cells from bright to dim as they age, so still lifes stand out from active regions; A toggles it at runtime")
+	flagTrail         = flag.Int("trail", 0, "frames for which cells that died leave a fading trail behind, so the paths of gliders show; 0 disables trails")
+	flagHeatmap       = flag.Bool("heatmap", false, "start in the heatmap view, which colors cells by how often they changed state recently; H toggles it at runtime")
+	flagHeatWindow    = flag.Int("heat-window", 100, "number of generations the heatmap counts changes over")
+	flagNeighbourView = flag.Bool("neighbour-view", false, "tint every cell by its number of live neighbours, to show the rule at work; N toggles it at runtime")
+	flagPost          = flag.String("post", "", "comma-separated post-processing effects to start with, of crt, bloom and chroma; F5, F6 and F7 toggle them at runtime")
+	flagBloom         = flag.Float64("bloom-intensity", 1, "strength of the glow the bloom effect adds around live cells")
+	flagGridLines     = flag.Bool("grid-lines", false, "outline the cells of square grids when zoomed in far enough to edit them precisely; G toggles it at runtime")
+	flagTPS           = flag.Int("tps", ebiten.DefaultTPS, "generations per second, which Ebiten also handles input at, 0 for as many as the world can compute; [ and ] halve and double it, U uncaps it")
+	flagVsync         = flag.Bool("vsync", true, "sync frames to the display's refresh rate; V toggles it at runtime")
+	flagSprite        = flag.String("sprite", "", "draw cells as sprites once zoomed in: a .png file, white where the cell's color goes, or one of "+strings.Join(spriteNames(), ", "))
+	flagAnimate       = flag.Bool("animate", false, "animate births and deaths, with newborn cells growing in and dead ones fading out")
+	flagHistory       = flag.Bool("history", false, "show the last generations as an isometric stack of layers, toggled with Y")
+	flagHistoryDepth  = flag.Int("history-depth", historyDepth, "number of generations in the history stack")
+	flagScreenshots   = flag.String("screenshot-dir", ".", "directory S, Shift+S and Ctrl+S write screenshots and SVG drawings to")
+	flagClipFormat    = flag.String("clip-format", "gif", "format C records clips in: "+strings.Join(clipFormatNames(), ", "))
+	flagClipSkip      = flag.Int("clip-skip", 2, "frames left out of a clip after every frame recorded")
+	flagGIFPalette    = flag.String("gif-palette", "adaptive", "colors of GIF recordings: "+strings.Join(gifPaletteNames(), ", "))
+	flagGIFColors     = flag.Int("gif-colors", 64, "number of colors in the adaptive GIF palette, at most 256")
+	flagFFmpeg        = flag.String("ffmpeg", "ffmpeg", "ffmpeg executable F9 records videos with")
+	flagVideoFormat   = flag.String("video-format", "mp4", "format of recorded videos: "+strings.Join(videoFormatNames(), ", "))
+	flagStream        = flag.String("stream", "", "run without a window and serve the frames as an MJPEG stream over HTTP on this address, e.g. :8080; build with -tags ebitencbackend to run it where there is no display")
+	flagExport        = flag.String("export", "", "run without a window and write numbered PNG frames to this directory; build with -tags ebitencbackend to run it where there is no display")
+	flagExportTicks   = flag.Int("export-ticks", 1000, "number of ticks -export runs for")
+	flagExportEvery   = flag.Int("export-every", 1, "write a frame every this many ticks with -export")
+	flagExportScale   = flag.Int("export-scale", 0, "pixels per cell of -export frames, 0 for the usual cell size")
+	flagDensity       = flag.Float64("density", 0.1, "fraction of cells alive in the initial random soup")
+	flagRuleExpr      = flag.String("rule-expr", "", "expression over state, alive and n (the number of live neighbours) giving the next state, e.g. 'alive ? n==2||n==3 : n==3'; overrides -rule")
+	flagRuleScript    = flag.String("rule-script", "", "Lua file defining the transition function of a custom rule; overrides -rule")
+	flagRuleWasm      = flag.String("rule-wasm", "", "WebAssembly module exporting next(state, neighbours) that implements a custom rule, reloaded whenever the file changes; overrides -rule")
+	flagSpecies       = flag.String("species", "", "comma separated Life-like rules of competing species sharing the grid, e.g. B3/S23,B36/S23; overrides -rule")
+	flagCollision     = flag.String("collision", "majority", "how births claimed by several species are settled: majority, priority or annihilation")
+	flagForestFire    = flag.String("forest-fire", "", "run the forest-fire model with tree growth probability p and lightning probability f, e.g. p=0.01,f=0.00005, or \"default\"; overrides -rule")
+	flagNoise         = flag.Float64("noise", 0, "fraction of random cells flipped after every generation")
+	flagTemperature   = flag.Float64("temperature", 0, "probability that the outcome of the rule is flipped for each cell and tick")
+	flagZones         = flag.String("zones", "", "comma separated rules of further zones, run side by side with -rule in vertical bands that can be repainted in edit mode")
+	flagRadius        = flag.Int("radius", 1, "radius of the Moore neighbourhood Life-like rules count live cells in")
+	flagMaxAge        = flag.Int("max-age", 0, "generations after which live cells die of old age; 0 lets them live forever")
+	flagMutateEvery   = flag.Int("mutate-every", 0, "mutate a Life-like rule by one neighbour count every this many generations; 0 keeps the rule fixed")
+	flagAnts          = flag.Int("ants", 0, "number of turmites walking the grid; use -rule static -density 0 for the classic Langton's ant")
+	flagAntSpeed      = flag.Int("ant-speed", 1, "steps each turmite takes per tick")
+	flagTurmite       = flag.String("turmite", "RL", "turmite program: a multi-color ant string such as RL or RLLR, or a transition table file")
+	flagLenia         = flag.String("lenia", "", "run the continuous Lenia automaton with these parameters, e.g. R=13,T=10,mu=0.15,sigma=0.015, or \"default\"")
+	flagLeniaScale    = flag.Int("lenia-scale", 4, "size in pixels of a Lenia cell")
+	flagSandpile      = flag.Int("sandpile", -1, "run the Abelian sandpile, dropping this many grains on the centre every tick; 0 leaves the dropping to the mouse")
+	flagSandpileScale = flag.Int("sandpile-scale", 4, "size in pixels of a sandpile cell")
+	flagWaTor         = flag.String("wator", "", "run the Wa-Tor predator-prey simulation with these parameters, e.g. fish=0.3,sharks=0.05,fbreed=3,sbreed=10,energy=3,gain=3, or \"default\"")
+	flagWaTorScale    = flag.Int("wator-scale", 4, "size in pixels of a Wa-Tor cell")
+	flagElementary    = flag.Int("elementary", -1, "run the one-dimensional elementary automaton with this Wolfram rule number (0-255) instead of a 2D world")
+)
+
+// Simulation is a world the update loop can advance tick by tick and the
+// renderer can draw.
+type Simulation interface {
+	Update(t *time.Time)
+	Draw(dc *gg.Context, p palette)
 }
 
 // World represents the game state.
 type World struct {
-	area   []bool
+	area []State
+	// next is the buffer the following generation is written to before it
+	// is swapped with area.
+	next   []State
 	width  int
 	height int
+	rule   Rule
+	// phase counts the ticks run under a block rule, selecting the offset
+	// of the Margolus partition.
+	phase    int
+	turmites []*Turmite
+	// ages holds the age of every cell, see updateAges, and prev the
+	// states of the previous generation it is computed from.
+	ages []uint16
+	prev []State
+	// heat counts the changes of every cell while HeatWindow is set.
+	heat heatmap
+	// flags marks walls and immortal cells, if there are any.
+	flags []CellFlags
+	// chunks tracks which parts of the world are still changing.
+	chunks chunkTracker
+	// zones assigns every cell to a zone when the world is partitioned, see
+	// SetZoneRules; zoneRules are the rules of the zones after zone 0.
+	zones     []uint8
+	zoneRules []Rule
+	// sinceMutation counts the generations since the rule last mutated.
+	sinceMutation int
+	// rng is the world's own source of randomness, used for seeding and by
+	// stochastic rules, so that a world can be replayed from its seed.
+	rng *rand.Rand
+
+	// Noise is the fraction of cells flipped at random after every
+	// generation, which keeps soups from ever settling down.
+	Noise float64
+	// Reverse runs reversible block rules backwards, undoing one tick per
+	// tick.
+	Reverse bool
+	// Radius, if greater than 1, makes a Life-like rule count the live
+	// cells in the Moore neighbourhood of that radius rather than in its own
+	// neighbourhood.
+	Radius int
+	// MaxAge, if positive, is the number of generations after which a live
+	// cell dies of old age whatever its neighbours.
+	MaxAge int
+	// HeatWindow, if positive, makes the world count how often every cell
+	// changed state over the last HeatWindow generations, see Heat.
+	HeatWindow int
+	// MutateEvery, if positive, makes a Life-like rule evolve: every
+	// MutateEvery generations one neighbour count is added to or removed
+	// from its birth or survival set.
+	MutateEvery int
+	// AntSteps is the number of steps each turmite takes per tick, after
+	// the rule has been applied.
+	AntSteps int
+	// Workers is the number of goroutines the world is updated on, each
+	// taking a band of rows. Rules that aren't safe for concurrent use
+	// always run on one.
+	Workers int
+	// FullUpdates makes every generation apply the rule to every cell,
+	// rather than skip the chunks that can't have changed, as the naive
+	// engine does to serve as the reference for the others.
+	FullUpdates bool
+	// pool runs the bands once Workers is above 1.
+	pool *workerPool
+	// IncrementalCounts makes Life-like rules on the Moore neighbourhood
+	// keep every cell's number of live neighbours across generations and
+	// only adjust it around births and deaths, see updateIncremental.
+	IncrementalCounts bool
+	// counter holds those counts.
+	counter neighbourCounts
+	// scratch holds the buffers counting and weighted rules sum into.
+	scratch scratchBuffers
+	// population is the number of cells that aren't dead, and births and
+	// deaths the number that came to life and died in the last Update.
+	population     int
+	births, deaths int
+	// entropy and clustering are the metrics computed if Metrics is set.
+	entropy, clustering float64
+	// bounds is the box around the cells that aren't dead.
+	bounds image.Rectangle
+	// seedCells is the number of random live cells the world was created
+	// with, see Reseed.
+	seedCells int
+	// cycles spots the world repeating itself, and period is the period
+	// of the cycle it is in, if any.
+	cycles cycleDetector
+	period int
+	// onBirth and onDeath are the handlers of births and deaths, and
+	// changes records them during Update.
+	onBirth, onDeath []func(x, y int)
+	changes          []cellChange
+	// subscribers receive diff, the net changes of every Update, worked
+	// out with the help of flipped.
+	subscribers []*subscription
+	diff        []Change
+	flipped     []bool
+	// generation is the number of the current generation, and
+	// tickDuration how long it took to compute.
+	generation   int
+	tickDuration time.Duration
+
+	// Boundary determines how cells beyond the edges of the world are
+	// resolved when gathering neighbours.
+	Boundary BoundaryMode
+
+	// Neighbourhood determines which cells are gathered as neighbours for
+	// the rule. Counting and block rules always use their own square
+	// neighbourhoods.
+	Neighbourhood Neighbourhood
+
+	// Anchor is the point of the world that stays put when it is resized.
+	Anchor Anchor
+
+	// Metrics makes every Update compute the entropy and clustering
+	// metrics of Stats, which cost several passes over the world.
+	Metrics bool
 }
 
-// NewWorld creates a new world.
+// NewWorld creates a new world running Conway's Game of Life.
 func NewWorld(width, height int, maxInitLiveCells int) *World {
 	w := &World{
-		area:   make([]bool, width*height),
-		width:  width,
-		height: height,
+		area:      make([]State, width*height),
+		width:     width,
+		height:    height,
+		rule:      Conway,
+		rng:       newRand(),
+		seedCells: maxInitLiveCells,
+
+		AntSteps: 1,
+		Workers:  runtime.NumCPU(),
 	}
 	w.init(maxInitLiveCells)
 	return w
 }
 
+// NewHighLifeWorld creates a new world running HighLife (B36/S23).
+func NewHighLifeWorld(width, height int, maxInitLiveCells int) *World {
+	w := NewWorld(width, height, maxInitLiveCells)
+	w.SetRule(HighLife)
+	return w
+}
+
 // init inits world with a random state.
 func (w *World) init(maxLiveCells int) {
 	for i := 0; i < maxLiveCells; i++ {
-		x := rand.Intn(w.width)
-		y := rand.Intn(w.height)
-		w.area[y*w.width+x] = true
+		w.Set(w.rng.Intn(w.width), w.rng.Intn(w.height), true)
+	}
+}
+
+// fillRandom fills every cell with a uniformly random state below states,
+// the soup that cyclic automata are usually started from.
+func (w *World) fillRandom(states int) {
+	for i := range w.area {
+		w.set(i, State(w.rng.Intn(states)))
+	}
+}
+
+// Rand returns the world's random number generator.
+func (w *World) Rand() *rand.Rand {
+	return w.rng
+}
+
+// nextBuffer returns the buffer to write the next generation to, see swap.
+// It still holds an earlier generation, so every cell must be written.
+func (w *World) nextBuffer() []State {
+	if len(w.next) != len(w.area) {
+		w.next = make([]State, len(w.area))
+	}
+	return w.next
+}
+
+// swap makes the buffer returned by nextBuffer the current generation.
+func (w *World) swap() {
+	w.area, w.next = w.next, w.area
+}
+
+// set changes cell i to s, keeping the population up to date.
+func (w *World) set(i int, s State) {
+	w.chunks.invalidate()
+	w.recordChange(i, w.area[i], s)
+	w.countChange(w.area[i], s)
+	if s != Dead {
+		w.growBounds(i)
+	}
+	w.area[i] = s
+}
+
+// countChange accounts for a cell changing state in the population and the
+// births and deaths of the current generation.
+func (w *World) countChange(from, to State) {
+	switch live(to) - live(from) {
+	case 1:
+		w.population++
+		w.births++
+	case -1:
+		w.population--
+		w.deaths++
+	}
+}
+
+// Get returns the state of the cell at (x, y). Cells outside the world are
+// dead.
+func (w *World) Get(x, y int) State {
+	if x < 0 || y < 0 || x >= w.width || y >= w.height {
+		return Dead
+	}
+	return w.area[y*w.width+x]
+}
+
+// Alive reports whether the cell at (x, y) is in any state but Dead. Cells
+// outside the world are dead.
+func (w *World) Alive(x, y int) bool {
+	return w.Get(x, y) != Dead
+}
+
+// Set makes the cell at (x, y) alive or dead. Cells outside the world are
+// left alone.
+func (w *World) Set(x, y int, alive bool) {
+	s := Dead
+	if alive {
+		s = Alive
+	}
+	w.SetState(x, y, s)
+}
+
+// SetState sets the cell at (x, y) to s, for rules with states beyond Dead
+// and Alive. Cells outside the world are left alone.
+func (w *World) SetState(x, y int, s State) {
+	if x < 0 || y < 0 || x >= w.width || y >= w.height {
+		return
+	}
+	w.set(y*w.width+x, s)
+}
+
+// Toggle kills the cell at (x, y) if it is in any state but Dead, and
+// brings it to life otherwise.
+func (w *World) Toggle(x, y int) {
+	w.Set(x, y, w.Get(x, y) == Dead)
+}
+
+// Summary returns the generation and population of the world in the form
+// "Gen 12,345 — pop 8,201".
+func (w *World) Summary() string {
+	return fmt.Sprintf("Gen %s — pop %s", groupDigits(w.Generation()), groupDigits(w.Population()))
+}
+
+// groupDigits formats n with commas between groups of three digits.
+func groupDigits(n int) string {
+	s := strconv.Itoa(n)
+	neg := strings.HasPrefix(s, "-")
+	if neg {
+		s = s[1:]
+	}
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	if neg {
+		s = "-" + s
+	}
+	return s
+}
+
+// live returns 1 for the states that count towards the population, those
+// other than Dead, and 0 for Dead.
+func live(s State) int {
+	if s == Dead {
+		return 0
+	}
+	return 1
+}
+
+// Population returns the number of cells that aren't dead.
+func (w *World) Population() int {
+	return w.population
+}
+
+// Reset kills every cell and forgets their ages and heat, without
+// reallocating the world. Walls, immortal cells and zones stay where they
+// are.
+func (w *World) Reset() {
+	for i := range w.area {
+		w.area[i] = Dead
+	}
+	w.population = 0
+	w.bounds = image.Rectangle{}
+	w.cycles.reset()
+	w.period = 0
+	w.generation = 0
+	for i := range w.ages {
+		w.ages[i] = 0
+	}
+	w.heat.reset()
+	w.phase = 0
+	w.chunks.invalidate()
+}
+
+// SetRule switches the world to the given rule. Rules whose rulestring
+// names a neighbourhood, like the hexagonal "B2/S34H", also switch the
+// world's Neighbourhood.
+func (w *World) SetRule(r Rule) {
+	w.rule = r
+	if nr, ok := r.(neighbourhoodRule); ok {
+		w.Neighbourhood = nr.Neighbourhood()
 	}
 }
 
 // Update game state by one tick.
 func (w *World) Update(t *time.Time) {
+	w.Step(1)
+}
+
+// Step advances the world by n generations. Birth and death handlers and
+// subscribers still see every one of them, but the bounds and metrics are
+// only worked out for the last, and callers redraw once rather than after
+// every generation.
+func (w *World) Step(n int) {
+	for ; n > 0; n-- {
+		w.tick(n == 1)
+	}
+}
+
+// tick advances the world by one generation. last is false for the
+// generations of a Step that are passed over on the way to a later one.
+func (w *World) tick(last bool) {
+	start := time.Now()
+	defer func() {
+		w.tickDuration = time.Since(start)
+	}()
+	if br, ok := w.rule.(*BlockRule); ok && w.Reverse && br.Reversible() {
+		w.generation--
+	} else {
+		w.generation++
+	}
+	w.prev = append(w.prev[:0], w.area...)
+	w.changes = w.changes[:0]
+	w.births, w.deaths = 0, 0
+	w.updateCells()
+	w.addNoise()
+	w.applyCellFlags()
+	w.updateAges(w.prev)
+	w.updateHeat(w.prev)
+	w.mutateRule()
+	w.updateTurmites()
+	w.period = w.cycles.observe(w.area)
+	if last {
+		w.updateBounds()
+		if w.Metrics {
+			w.updateMetrics()
+		}
+	}
+	w.fireEvents()
+}
+
+// Generation returns the number of generations the world has advanced
+// since it was created or last reset. Running a reversible rule backwards
+// counts down.
+func (w *World) Generation() int {
+	return w.generation
+}
+
+// TickDuration returns how long the last generation took to compute.
+func (w *World) TickDuration() time.Duration {
+	return w.tickDuration
+}
+
+// Period returns the period of the cycle the world has entered, or 0 if it
+// hasn't been in its current state in the last cycleHistory generations.
+// Still lifes have period 1.
+func (w *World) Period() int {
+	return w.period
+}
+
+// updateCells applies the rule to every cell. A stochastic rule without
+// zones is run by whichever update its wrapped rule needs, and the
+// temperature then applied to the result.
+func (w *World) updateCells() {
+	if sr, ok := w.rule.(*StochasticRule); ok && w.zones == nil {
+		w.rule = sr.Rule
+		w.updateCells()
+		w.rule = sr
+		w.applyTemperature(sr)
+		return
+	}
+	switch rule := w.rule.(type) {
+	case static:
+		if w.zones == nil {
+			return
+		}
+	case *BlockRule:
+		w.updateBlocks(rule)
+		return
+	case *WeightedRule:
+		w.updateWeighted(rule)
+		return
+	case *LifeRule:
+		if w.Radius > 1 && w.zones == nil {
+			w.updateCounting(radiusRule{rule, w.Radius})
+			return
+		}
+		if w.IncrementalCounts && w.Neighbourhood == Moore && w.zones == nil {
+			w.updateIncremental(rule)
+			return
+		}
+	case countingRule:
+		w.updateCounting(rule)
+		return
+	}
+
+	if tr, ok := w.rule.(tickingRule); ok {
+		tr.beginTick()
+	}
 	width := w.width
 	height := w.height
-	next := make([]bool, width*height)
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			pop := neighbourCount(w.area, width, height, x, y)
-			switch {
-			case pop < 2:
-				// rule 1. Any live cell with fewer than two live neighbours
-				// dies, as if caused by under-population.
-				next[y*width+x] = false
-
-			case (pop == 2 || pop == 3) && w.area[y*width+x]:
-				// rule 2. Any live cell with two or three live neighbours
-				// lives on to the next generation.
-				next[y*width+x] = true
-
-			case pop > 3:
-				// rule 3. Any live cell with more than three live neighbours
-				// dies, as if by over-population.
-				next[y*width+x] = false
-
-			case pop == 3:
-				// rule 4. Any dead cell with exactly three live neighbours
-				// becomes a live cell, as if by reproduction.
-				next[y*width+x] = true
+	next := w.nextBuffer()
+	lr, _ := w.rule.(*LifeRule)
+	mortal := lr != nil && lr.mortal() && w.zones == nil
+	dirty := w.dirtyChunks()
+	cw, ch := chunkGrid(width, height)
+	// tallies[cy0] counts the births and deaths in the band starting at
+	// chunk row cy0.
+	tallies := make([]bandTally, ch)
+	track := w.tracking()
+	w.forEachBand(func(cy0, cy1 int) {
+		neighbours := make([]State, 0, 8)
+		for c := cy0 * cw; c < cy1*cw; c++ {
+			r := chunkRect(c, width, height)
+			if dirty != nil && !dirty[c] {
+				for y := r.Min.Y; y < r.Max.Y; y++ {
+					copy(next[y*width+r.Min.X:y*width+r.Max.X], w.area[y*width+r.Min.X:])
+				}
+				continue
+			}
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					self := w.area[y*width+x]
+					// In mortal rules live cells always die.
+					s := Dead
+					if !mortal || self != Alive {
+						neighbours = neighbourStates(neighbours[:0], w.area, width, height, x, y, w.Boundary, w.Neighbourhood)
+						s = w.ruleAt(y*width+x).Next(self, neighbours)
+					}
+					next[y*width+x] = s
+					if live(s) != live(self) {
+						tallies[cy0].add(y*width+x, s != Dead, track)
+					}
+				}
 			}
 		}
+	})
+	for _, t := range tallies {
+		w.births += t.births
+		w.deaths += t.deaths
+		w.population += t.births - t.deaths
+		w.changes = append(w.changes, t.changes...)
+	}
+	w.swap()
+}
+
+// bandTally counts the births and deaths in one band of a parallel update,
+// and records them if they are being tracked.
+type bandTally struct {
+	births, deaths int
+	changes        []cellChange
+}
+
+func (t *bandTally) add(i int, born, track bool) {
+	if born {
+		t.births++
+	} else {
+		t.deaths++
+	}
+	if track {
+		t.changes = append(t.changes, cellChange{i, born})
 	}
-	w.area = next
 }
 
 func max(a, b int) int {
@@ -116,55 +614,360 @@ func min(a, b int) int {
 	return b
 }
 
-// neighbourCount calculates the Moore neighborhood of (x, y).
-func neighbourCount(a []bool, width, height, x, y int) int {
-	c := 0
-	for j := -1; j <= 1; j++ {
-		for i := -1; i <= 1; i++ {
-			if i == 0 && j == 0 {
-				continue
-			}
-			x2 := x + i
-			y2 := y + j
-			if x2 < 0 || y2 < 0 || width <= x2 || height <= y2 {
-				continue
-			}
-			if a[y2*width+x2] {
-				c++
-			}
+// neighbourStates appends the states of the neighbours of (x, y) to dst, in
+// the order given by the neighbourhood's offsets; for Moore that is
+// row-major, starting at the top-left neighbour. Neighbours outside the
+// world are resolved according to the boundary mode.
+func neighbourStates(dst []State, a []State, width, height, x, y int, boundary BoundaryMode, nb Neighbourhood) []State {
+	for _, o := range nb.offsets(x, y) {
+		x2, y2, ok, alive := boundary.resolve(width, height, x+o[0], y+o[1])
+		switch {
+		case ok:
+			dst = append(dst, a[y2*width+x2])
+		case alive:
+			dst = append(dst, Alive)
+		default:
+			dst = append(dst, Dead)
 		}
 	}
-	return c
+	return dst
 }
 
-// Draw renders current world state.
-func (w *World) Draw(dc *gg.Context) {
-	for i, v := range w.area {
-		if v {
-			dc.SetPixel(i%w.width, i/w.height)
+// Draw renders current world state. Dead cells are left untouched; every
+// other cell is painted with its color from p. Hexagonal worlds are drawn as
+// a hexagon grid with one hexagon per cell, triangular ones with one
+// triangle per cell.
+func (w *World) Draw(dc *gg.Context, p palette) {
+	switch w.Grid() {
+	case HexGrid:
+		w.drawHex(dc, p, themes[defaultTheme].Grid)
+		return
+	case TriangleGrid:
+		w.drawTriangles(dc, p)
+		return
+	}
+	w.drawZones(dc)
+	last := Dead
+	w.ForEachIn(w.bounds, func(x, y int, v State) {
+		if v == Dead {
+			return
+		}
+		if v != last {
+			dc.SetColor(p.color(v))
+			last = v
 		}
+		dc.SetPixel(x, y)
+	})
+	w.drawCellFlags(dc)
+	w.drawTurmites(dc)
+}
+
+// drawHex renders the world as a Hexago grid of width columns and height
+// rows, filling the hexagon of every cell that isn't dead. Walls, immortal
+// cells and the cells turmites stand on are filled in their own colors,
+// just as they are painted over the pixel of the cell on the square grid.
+//
+// The outlines of the hexagons are drawn in gridColor.
+func (w *World) drawHex(dc *gg.Context, p palette, gridColor color.Color) {
+	grid := Hexago.MakeHexGridWithContext(dc, float64(w.height), float64(w.width))
+	r, g, b, a := rgbaFloats(gridColor)
+	grid.SetStrokeAll(r, g, b, a, 1)
+	fill := func(x, y int, c color.Color) {
+		r, g, b, a := rgbaFloats(c)
+		grid.SetFill(y, x, r, g, b, a)
 	}
+	w.ForEachIn(w.bounds, func(x, y int, v State) {
+		if v != Dead {
+			fill(x, y, p.color(v))
+		}
+	})
+	for i, f := range w.flags {
+		switch {
+		case f&CellWall != 0:
+			fill(i%w.width, i/w.width, wallColor)
+		case f&CellImmortal != 0:
+			fill(i%w.width, i/w.width, immortalColor)
+		}
+	}
+	for _, t := range w.turmites {
+		fill(t.X, t.Y, turmiteColor)
+	}
+	grid.DrawGrid()
+}
+
+// palette maps cell states to colors. The Dead entry is the background.
+type palette []color.Color
+
+// color returns the color of state s, falling back to the last entry for
+// states the palette doesn't cover.
+func (p palette) color(s State) color.Color {
+	if int(s) < len(p) {
+		return p[s]
+	}
+	return p[len(p)-1]
+}
+
+var (
+	// dayAndNightPalette gives both states an equally strong color, since
+	// neither is the natural background of a symmetric rule.
+	dayAndNightPalette = palette{
+		Dead:  color.RGBA{0x1a, 0x23, 0x7e, 0xff},
+		Alive: color.RGBA{0xff, 0xd5, 0x4f, 0xff},
+	}
+
+	// briansBrainDying is the color of dying cells in Brian's Brain, which
+	// keeps its blue in every theme.
+	briansBrainDying = color.RGBA{0x40, 0x80, 0xff, 0xff}
+
+	forestFirePalette = palette{
+		FireEmpty:   color.Black,
+		FireTree:    color.RGBA{0x2e, 0x9e, 0x3a, 0xff},
+		FireBurning: color.RGBA{0xff, 0x8c, 0x1a, 0xff},
+	}
+
+	wireworldPalette = palette{
+		WireEmpty:     color.Transparent,
+		WireHead:      color.RGBA{0x30, 0x90, 0xff, 0xff},
+		WireTail:      color.RGBA{0xff, 0x40, 0x30, 0xff},
+		WireConductor: color.RGBA{0xff, 0xb0, 0x00, 0xff},
+	}
+)
+
+// decayPalette returns a palette for a Generations rule with the given number
+// of states: live cells are in the theme's Live color and decaying cells
+// fade from its Decay color towards the background.
+func decayPalette(states int, t *Theme) palette {
+	p := make(palette, states)
+	p[Dead] = t.Background
+	p[Alive] = t.Live
+	r, g, b, _ := t.Decay.RGBA()
+	for s := int(Dying); s < states; s++ {
+		a := uint8(0xff * (states - s) / (states - 1))
+		p[s] = color.NRGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), a}
+	}
+	return p
+}
+
+// huePalette returns a palette of n colors spread evenly around the color
+// wheel. Every state, Dead included, gets a color.
+func huePalette(n int) palette {
+	p := make(palette, n)
+	for i := range p {
+		p[i] = hsv(360*float64(i)/float64(n), 0.8, 0.9)
+	}
+	return p
+}
+
+// hsv converts a color from HSV, with h in degrees, to RGB.
+func hsv(h, s, v float64) color.Color {
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g = c, x
+	case h < 120:
+		r, g = x, c
+	case h < 180:
+		g, b = c, x
+	case h < 240:
+		g, b = x, c
+	case h < 300:
+		r, b = x, c
+	default:
+		r, b = c, x
+	}
+	m := v - c
+	return color.RGBA{uint8(0xff * (r + m)), uint8(0xff * (g + m)), uint8(0xff * (b + m)), 0xff}
+}
+
+// turmitePalette returns a palette for worlds painted by turmites with n
+// colors: color 0 is the theme's background, the others are spread around
+// the color wheel.
+func turmitePalette(n int, t *Theme) palette {
+	p := huePalette(n - 1)
+	return append(palette{Dead: t.Background}, p...)
+}
+
+// paletteFor picks the palette to draw a world running rule in theme t.
+func paletteFor(rule Rule, t *Theme) palette {
+	switch rule := rule.(type) {
+	case *LifeRule:
+		if rule.String() == DayAndNight.String() {
+			return dayAndNightPalette
+		}
+	case *GenerationsRule:
+		if rule == BriansBrain {
+			return palette{Dead: t.Background, Alive: t.Live, Dying: briansBrainDying}
+		}
+		return decayPalette(rule.States(), t)
+	case *LtLRule:
+		if rule.States() > 2 {
+			return decayPalette(rule.States(), t)
+		}
+	case wireworld:
+		return t.onBackground(wireworldPalette)
+	case *CyclicRule:
+		return huePalette(rule.States())
+	case *ForestFireRule:
+		return forestFirePalette
+	case *ScriptRule:
+		if rule.States() > 2 {
+			return decayPalette(rule.States(), t)
+		}
+	case *WasmRule:
+		if rule.States() > 2 {
+			return decayPalette(rule.States(), t)
+		}
+	case multiColorRule:
+		return turmitePalette(rule.Colors()+1, t)
+	case *StochasticRule:
+		return paletteFor(rule.Rule, t)
+	}
+	return t.palette()
 }
 
 const (
 	screenWidth  = 640
 	screenHeight = 480
+
+	// hexCols and hexRows are the dimensions of hexagonal worlds, chosen
+	// so the hexagons fill the screen.
+	hexCols = 64
+	hexRows = 40
 )
 
 type Renderer struct {
-	world    *World
-	ch       chan struct{}
+	sim      Simulation
 	dc       *gg.Context
 	shutdown atomic.Value
+
+	// mu guards the simulation. The world update loop holds it for
+	// writing while it runs a tick or applies an edit, and the renderer
+	// holds it for reading while it draws the simulation or inspects it
+	// to handle input, so either sees the simulation between ticks and
+	// never while it changes. version counts the changes made under the
+	// write lock; frame is the simulation as drawn at frameVersion from
+	// frameAt, the frameOrigin at the time, reused until either moves on.
+	mu           sync.RWMutex
+	version      int
+	frame        *ebiten.Image
+	frameVersion int
+	frameAt      image.Point
+	// frameSprites is whether the frame leaves the cells to drawSprites,
+	// which draws them from sprite, made from spriteSrc, over it.
+	frameSprites bool
+	sprite       *ebiten.Image
+	spriteSrc    image.Image
+	// background is the background of frames drawn by the pixel path,
+	// for the Dead color backgroundColor in backgroundTheme, see
+	// backgroundPixels.
+	background      []byte
+	backgroundColor color.Color
+	backgroundTheme *Theme
+	// theme is the set of colors the simulation is drawn in. ageColors
+	// fades the cells of worlds by age, see DrawAgePixels, and heatmap
+	// shows how active they are instead, over a window of heatWindow
+	// generations, see DrawHeatPixels.
+	theme      *Theme
+	ageColors  bool
+	heatmap    bool
+	heatWindow int
+	// neighbourView tints every cell by its number of live neighbours,
+	// counted into neighbours, see drawNeighbourPixels.
+	neighbourView bool
+	neighbours    []uint8
+	// post is the post-processing applied to the composed world.
+	post *postProcessor
+	// gridLines outlines the cells once they are large enough, see
+	// drawGridLines.
+	gridLines bool
+	// tps is the tick cap to restore once ticks are no longer uncapped,
+	// see handleTiming, and worldTPS hands the ticks per second to the
+	// world update loop, see setTPS.
+	tps      int
+	worldTPS chan int
+	// hudHidden hides the HUD, see drawHUD.
+	hudHidden bool
+	// graph is the population history of worlds, recorded by afterUpdate.
+	graph populationGraph
+	// debug is the overlay of frame timings and memory statistics.
+	debug debugOverlay
+	// animating animates births and deaths, see drawAnimation.
+	animating bool
+	anim      animation
+	// history is the isometric stack of the last generations, recorded by
+	// afterUpdate.
+	history historyStack
+	// screenshot is the screenshot the next Draw takes, into the directory
+	// screenshotDir, and toast the message confirming the last one.
+	screenshot    screenshotKind
+	screenshotDir string
+	toast         toast
+	// clip records the screen as an animated image, see handleRecording.
+	clip clipRecorder
+	// video records the screen through ffmpeg, see handleVideo.
+	video videoRecorder
+	// screen is the size of the screen as last laid out, in physical
+	// pixels, deviceScale the number of them per logical pixel, and
+	// windowSize the size of the window before it went fullscreen.
+	screen      image.Point
+	deviceScale float64
+	windowSize  image.Point
+	// trail is the decay buffer of the phosphor trail effect, see
+	// drawTrail, and trailFrames how many frames a trail lasts.
+	trail       []uint8
+	trailFrames int
+	// cellSize is the size in pixels of a cell on the screen. The frame
+	// is drawn at one pixel per cell and scaled up by it.
+	cellSize int
+
+	// editing pauses the simulation and lets the mouse paint cells. Edits
+	// are queued on edits and applied by the world update loop, which owns
+	// the world.
+	editing atomic.Value
+	edits   chan func()
+	brush   brush
+	// camera is the part of the world shown on the screen, and drag the
+	// state of the mouse drag panning it.
+	camera Camera
+	drag   struct {
+		active bool
+		from   image.Point
+	}
+	minimap minimap
+	// paused stops the simulation without entering editing mode, either
+	// from the keyboard or, if pauseOnCycle is set, as soon as a world
+	// enters a cycle.
+	paused       atomic.Value
+	pauseOnCycle bool
+	// onSettle is what to do once a world dies out or settles down, and
+	// settled whether it was already settled after the previous tick.
+	// lastPeriod is its period after the previous tick; see afterUpdate.
+	onSettle   SettleAction
+	settled    bool
+	lastPeriod int
+	// title is the window title last set.
+	title string
 }
 
-func NewRenderer(world *World, dc *gg.Context) *Renderer {
+func NewRenderer(sim Simulation, dc *gg.Context) *Renderer {
 	r := &Renderer{
-		world: world,
-		ch:    make(chan struct{}),
-		dc:    dc,
+		sim:         sim,
+		dc:          dc,
+		edits:       make(chan func(), 256),
+		cellSize:    1,
+		camera:      Camera{Zoom: 1},
+		theme:       themes[defaultTheme],
+		post:        newPostProcessor(),
+		screen:      image.Pt(screenWidth, screenHeight),
+		deviceScale: 1,
+		tps:         ebiten.DefaultTPS,
+		worldTPS:    make(chan int, 1),
 	}
 	r.shutdown.Store(false)
+	r.editing.Store(false)
+	r.paused.Store(false)
 	return r
 }
 
@@ -176,53 +979,241 @@ func (r *Renderer) Update() error {
 	if r.shutdown.Load().(bool) {
 		return errors.New("Shutdown")
 	}
+	start := time.Now()
+	defer func() {
+		r.debug.update = time.Since(start)
+	}()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	onMinimap := r.handleMinimap()
+	if !onMinimap {
+		r.handleEditing()
+	}
+	r.handlePause()
+	r.handleReverse()
+	r.handleFastForward()
+	r.handleCamera(onMinimap)
+	r.handleHashLifeStep()
+	r.handleTheme()
+	r.handleAgeColors()
+	r.handleHeatmap()
+	r.handleNeighbourView()
+	r.handlePostEffects()
+	r.handleGridLines()
+	r.handleFullscreen()
+	r.handleTiming()
+	r.handleHUD()
+	r.handleGraph()
+	r.handleDebug()
+	r.handleHistory()
+	r.handleScreenshot()
+	r.handleRecording()
+	r.handleVideo()
 	return nil
 }
 
 func (r *Renderer) DrawHexagonGrid() {
 	grid := Hexago.MakeHexGridWithContext(r.dc, 16, 25)
-	grid.SetStrokeAll(0.3, 0.3, 0.3, 1, 1)
+	cr, cg, cb, ca := rgbaFloats(r.theme.Grid)
+	grid.SetStrokeAll(cr, cg, cb, ca, 1)
 	grid.DrawGrid()
 }
 
 func (r *Renderer) Draw(screen *ebiten.Image) {
-	defer func() {
-		defer func() {
-			recover()
-		}()
-		recover()
-		r.ch <- struct{}{} // XXX: this is supposed to be invoked when Draw exits!
-	}()
-	<-r.ch
+	start := time.Now()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p := r.palette()
+	if r.frame == nil || r.frameVersion != r.version || r.frameAt != r.frameOrigin() || r.frameSprites != r.spritesOn() {
+		r.drawFrame(p)
+	}
+	// The world goes through the post-processing effects, if any are on,
+	// and the minimap and HUD are drawn over the result.
+	world := screen
+	post := len(r.post.active()) > 0
+	if post {
+		world = r.post.target(screen.Size())
+	}
+	// Fill around the world too when it is zoomed out.
+	world.Fill(p[Dead])
+	if r.historyShown() {
+		r.drawHistory(world, r.sim.(*World), p)
+	} else {
+		world.DrawImage(r.frame, &ebiten.DrawImageOptions{GeoM: r.frameGeoM()})
+		if ss, ok := r.sim.(screenSimulation); ok {
+			ss.DrawScreen(world, p, r.camera.GeoM())
+		}
+		if w, ok := r.sim.(*World); ok && r.frameSprites {
+			r.drawSprites(world, w, p)
+		} else if ok {
+			r.drawAnimation(world, w, p)
+		}
+	}
+	if post {
+		r.post.apply(screen)
+	}
+	if !r.historyShown() {
+		r.drawGridLines(screen)
+	}
+	r.takeScreenshot(screen, p)
+	r.captureClip(screen)
+	r.captureVideo(screen)
+	r.drawMinimap(screen, p)
+	r.drawGraph(screen)
+
+	if w, ok := r.sim.(*World); ok {
+		if title := windowTitle + " — " + w.Summary(); title != r.title {
+			ebiten.SetWindowTitle(title)
+			r.title = title
+		}
+	}
+
+	r.drawHUD(screen)
+	// The overlay shows the time the previous Draw took, this one isn't
+	// done yet.
+	r.drawDebug(screen)
+	r.drawToast(screen)
+	r.debug.draw = time.Since(start)
+}
+
+// drawFrame draws the simulation into r.frame.
+func (r *Renderer) drawFrame(p palette) {
+	r.frameSprites = r.spritesOn()
+	r.renderFrame(p, r.frameSprites)
+	r.uploadFrame()
+	r.frameVersion, r.frameAt = r.version, r.frameOrigin()
+}
+
+// renderFrame draws the simulation into r.dc, leaving out the cells of
+// worlds if sprites are drawn over them. Simulations that can write their
+// pixels directly are drawn over a copy of the background, and only the
+// others go through gg. It doesn't need Ebiten's game loop to run.
+func (r *Renderer) renderFrame(p palette, sprites bool) {
+	if ps, ok := r.sim.(pixelSimulation); ok {
+		img := r.dc.Image().(*image.RGBA)
+		copy(img.Pix, r.backgroundPixels(p))
+		draw := ps.DrawPixels
+		if w, ok := r.sim.(*World); ok && sprites {
+			// Leave the cells to drawSprites.
+			draw = func(img *image.RGBA, p palette) bool {
+				w.drawFlagPixels(img)
+				return true
+			}
+		} else if ok && r.neighbourView {
+			draw = func(img *image.RGBA, p palette) bool { return r.drawNeighbourPixels(w, img, p) }
+		} else if ok && r.heatmap {
+			draw = w.DrawHeatPixels
+		} else if ok && r.ageColors {
+			draw = w.DrawAgePixels
+		}
+		if draw(img, p) {
+			r.drawTrail(img)
+			return
+		}
+	}
 
 	// r.dc.DrawCircle(screenWidth/2, screenHeight/2, 20)
-	r.dc.SetRGBA(0, 0, 0, 0)
+	r.dc.SetColor(p[Dead])
 	r.dc.Clear()
 	// r.dc.SetLineWidth(0.5)
 	// r.dc.DrawRegularPolygon(6, screenWidth/2, screenHeight/2, 20, 0)
 	// r.dc.Stroke()
-	r.DrawHexagonGrid()
+	if w, ok := r.sim.(*World); !ok || w.Grid() == SquareGrid {
+		// Hexagonal worlds draw their own grid, and the hexagons don't
+		// belong under triangles.
+		r.DrawHexagonGrid()
+	}
 
-	r.dc.SetRGB(1, 1, 1)
-	r.world.Draw(r.dc)
-	screen.DrawImage(ebiten.NewImageFromImage(r.dc.Image()), nil)
+	if vs, ok := r.sim.(viewSimulation); ok {
+		o := r.frameOrigin()
+		vs.DrawView(r.dc, p, image.Rectangle{o, o.Add(image.Pt(r.dc.Width(), r.dc.Height()))})
+	} else if w, ok := r.sim.(*World); ok && w.Grid() == HexGrid {
+		w.drawHex(r.dc, p, r.theme.Grid)
+	} else {
+		r.sim.Draw(r.dc, p)
+	}
 }
 
-func (r *Renderer) Render() {
+// redraw makes Draw draw the frame and the minimap afresh, for changes to
+// how the simulation is drawn rather than to the simulation itself.
+func (r *Renderer) redraw() {
+	r.frameVersion = r.version - 1
+	r.minimap.version = r.version - minimapEvery
+}
+
+// backgroundPixels returns the pixels of the background the pixel path
+// draws over: the Dead color of p with the hexagon grid on top. They are
+// drawn with gg the first time and whenever the Dead color or the theme
+// changes.
+func (r *Renderer) backgroundPixels(p palette) []byte {
+	if r.background == nil || r.backgroundColor != p[Dead] || r.backgroundTheme != r.theme {
+		r.dc.SetColor(p[Dead])
+		r.dc.Clear()
+		r.DrawHexagonGrid()
+		r.background = append(r.background[:0], r.dc.Image().(*image.RGBA).Pix...)
+		r.backgroundColor, r.backgroundTheme = p[Dead], r.theme
+	}
+	return r.background
+}
+
+// uploadFrame copies the pixels of r.dc into r.frame. The texture is
+// created once and then overwritten in place, rather than allocating a new
+// one every time the simulation changes. gg draws into a premultiplied
+// RGBA image without padding, exactly the layout ReplacePixels takes.
+func (r *Renderer) uploadFrame() {
+	start := time.Now()
 	defer func() {
-		defer func() {
-			recover()
-		}()
-		recover()
-		<-r.ch
+		r.debug.upload = time.Since(start)
 	}()
-	r.ch <- struct{}{}
+	if r.frame == nil {
+		r.frame = ebiten.NewImage(r.dc.Width(), r.dc.Height())
+	}
+	r.frame.ReplacePixels(r.dc.Image().(*image.RGBA).Pix)
 }
 
+// palette picks the palette for the current simulation in the current
+// theme.
+func (r *Renderer) palette() palette {
+	if _, ok := r.sim.(*SandpileWorld); ok {
+		return r.theme.onBackground(sandpilePalette)
+	}
+	w, ok := r.sim.(*World)
+	if !ok {
+		return r.theme.palette()
+	}
+	p := paletteFor(w.rule, r.theme)
+	if n := w.turmiteColors(); n > len(p) {
+		p = turmitePalette(n, r.theme)
+	}
+	return p
+}
+
+// change runs f, which may change the simulation, under the write lock.
+func (r *Renderer) change(f func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f()
+	r.version++
+}
+
+// Layout implements ebiten.Game. The screen is as large as the window, or
+// the display in fullscreen, so that resizing the window shows more or less
+// of the world rather than stretching it. It is laid out in physical
+// pixels, so that on HiDPI displays cells are drawn sharp rather than
+// scaled up by Ebiten.
 func (r *Renderer) Layout(outsideWidth, outsideHeight int) (int, int) {
-	return screenWidth, screenHeight
+	s := ebiten.DeviceScaleFactor()
+	size := image.Pt(int(math.Ceil(float64(outsideWidth)*s)), int(math.Ceil(float64(outsideHeight)*s)))
+	r.setDeviceScale(s)
+	r.resizeScreen(size)
+	return size.X, size.Y
 }
 
+// windowTitle is the title of the window, followed by the generation and
+// population while a World runs.
+const windowTitle = "Game of Life (Ebiten Demo)"
+
 func StartRenderingLoop(r *Renderer, ch chan struct{}) {
 	go func() {
 		runtime.LockOSThread() // XXX: this is required!
@@ -230,12 +1221,12 @@ func StartRenderingLoop(r *Renderer, ch chan struct{}) {
 		defer func() {
 			recover()
 
-			close(r.ch)
 			ch <- struct{}{}
 		}()
 
 		ebiten.SetWindowSize(screenWidth, screenHeight)
-		ebiten.SetWindowTitle("Game of Life (Ebiten Demo)")
+		ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
+		ebiten.SetWindowTitle(windowTitle)
 		ebiten.SetWindowClosingHandled(true)
 		if err := ebiten.RunGame(r); err != nil {
 			log.Printf("err: %v", err)
@@ -243,30 +1234,377 @@ func StartRenderingLoop(r *Renderer, ch chan struct{}) {
 	}()
 }
 
-func RunWorldUpdateLoop(w *World, r *Renderer, ch chan struct{}) {
+// RunWorldUpdateLoop advances w every tick and applies the edits queued by
+// the renderer, until ch is signalled. It is the only goroutine that
+// changes w, and only does so under the renderer's write lock. The ticks
+// come as often as the renderer's ticks per second say, see setTPS, and
+// back to back while they are uncapped.
+func RunWorldUpdateLoop(w Simulation, r *Renderer, ch chan struct{}) {
 	shutdown := time.NewTimer(10 * time.Second)
-	ticker := time.NewTicker(100 * time.Millisecond)
+	ticker := time.NewTicker(time.Second / ebiten.DefaultTPS)
+	defer ticker.Stop()
+	uncapped := false
 Loop:
 	for {
+		tick := ticker.C
+		if uncapped && !r.Editing() && !r.Paused() {
+			tick = ready
+		}
 		select {
 		case <-ch:
 			break Loop
-		case t := <-ticker.C:
-			fmt.Println("ticker at: ", t)
-			w.Update(&t)
-			r.Render()
+		case edit := <-r.edits:
+			r.change(edit)
+		case tps := <-r.worldTPS:
+			uncapped = tps <= 0
+			if !uncapped {
+				ticker.Reset(time.Second / time.Duration(tps))
+			}
+		case <-tick:
+			if !r.Editing() && !r.Paused() {
+				t := time.Now()
+				r.change(func() {
+					w.Update(&t)
+					r.afterUpdate()
+				})
+			}
 		case <-shutdown.C:
 			r.Shutdown()
 		}
 	}
 }
 
+// worldFlags are the flags setting up a World that the engines other than
+// naive and parallel have no equivalent for.
+var worldFlags = []string{
+	"forest-fire", "temperature", "zones", "radius", "noise", "max-age", "mutate-every",
+	"ants", "ant-speed", "turmite", "workers", "incremental", "metrics",
+}
+
+// setFlags returns those of the named flags that were given on the command
+// line, with their dashes.
+func setFlags(names []string) []string {
+	var set []string
+	flag.Visit(func(f *flag.Flag) {
+		for _, name := range names {
+			if f.Name == name {
+				set = append(set, "-"+name)
+			}
+		}
+	})
+	return set
+}
+
+// ready is always ready to receive from, for a world update loop that
+// doesn't wait between ticks.
+var ready = func() chan time.Time {
+	c := make(chan time.Time)
+	close(c)
+	return c
+}()
+
 func main() {
-	w := NewWorld(screenWidth, screenHeight, int((screenWidth*screenHeight)/10))
-	r := NewRenderer(w, gg.NewContext(screenWidth, screenHeight))
+	flag.Parse()
+
+	seed := *flagSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	SetSeed(seed)
+	log.Printf("seed %d", seed)
+
+	boundary, err := ParseBoundaryMode(*flagBoundary)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var sim Simulation
+	// cellSize is the size of a cell in pixels for the worlds drawn one
+	// pixel per cell; the others are drawn at their own scale.
+	cellSize := 1
+	switch {
+	case *flagLenia != "":
+		spec := *flagLenia
+		if spec == "default" {
+			spec = ""
+		}
+		params, err := ParseLeniaParams(spec)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *flagLeniaScale < 1 {
+			log.Fatalf("lenia scale %d must be positive", *flagLeniaScale)
+		}
+		sim = NewLeniaWorld(screenWidth, screenHeight, *flagLeniaScale, params)
+	case *flagSandpile >= 0:
+		if *flagSandpileScale < 1 {
+			log.Fatalf("sandpile scale %d must be positive", *flagSandpileScale)
+		}
+		s := NewSandpileWorld(screenWidth / *flagSandpileScale, screenHeight / *flagSandpileScale, *flagSandpileScale)
+		s.Drip = *flagSandpile
+		sim = s
+	case *flagWaTor != "":
+		spec := *flagWaTor
+		if spec == "default" {
+			spec = ""
+		}
+		params, err := ParseWaTorParams(spec)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *flagWaTorScale < 1 {
+			log.Fatalf("wa-tor scale %d must be positive", *flagWaTorScale)
+		}
+		sim = NewWaTorWorld(screenWidth / *flagWaTorScale, screenHeight / *flagWaTorScale, *flagWaTorScale, params)
+	case *flagElementary >= 0:
+		if *flagElementary > 255 {
+			log.Fatalf("elementary rule %d out of range 0-255", *flagElementary)
+		}
+		e := NewElementaryWorld(screenWidth, screenHeight, uint8(*flagElementary))
+		e.Boundary = boundary
+		sim = e
+	default:
+		rule, err := LookupRule(*flagRule)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *flagRuleExpr != "" {
+			if rule, err = ParseExprRule(*flagRuleExpr); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if *flagRuleScript != "" {
+			if rule, err = LoadScriptRule(*flagRuleScript); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if *flagRuleWasm != "" {
+			if rule, err = LoadWasmRule(*flagRuleWasm); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if *flagSpecies != "" {
+			policy, err := ParseCollisionPolicy(*flagCollision)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if rule, err = ParseSpeciesRule(*flagSpecies, policy); err != nil {
+				log.Fatal(err)
+			}
+		}
+		nb := Moore
+		if nr, ok := rule.(neighbourhoodRule); ok {
+			nb = nr.Neighbourhood()
+		}
+		if *flagNeighbourhood != "" {
+			if nb, err = ParseNeighbourhood(*flagNeighbourhood); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if *flagGrid != "" {
+			grid, err := ParseGrid(*flagGrid)
+			if err != nil {
+				log.Fatal(err)
+			}
+			switch {
+			case *flagNeighbourhood == "":
+				if nb.grid() != grid {
+					nb = grid.neighbourhood()
+				}
+			case nb.grid() != grid:
+				log.Fatalf("neighbourhood %v doesn't fit the %v grid", nb, grid)
+			}
+		}
+		if *flagCellSize < 1 {
+			log.Fatalf("cell size %d must be positive", *flagCellSize)
+		}
+		cellSize = *flagCellSize
+		width, height := nb.grid().size(screenWidth/cellSize, screenHeight/cellSize)
+		cells := int(*flagDensity * float64(width*height))
+		lr, lifeLike := rule.(*LifeRule)
+		lifeLike = lifeLike && nb == Moore
+		if *flagCheckEngines > 0 {
+			if !lifeLike {
+				log.Fatal("-check-engines needs a Life-like rule on the Moore neighbourhood")
+			}
+			checked, err := checkEngines(lr, width, height, cells, *flagCheckEngines)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("engines %s agree on %d generations of %v\n", strings.Join(checked, ", "), *flagCheckEngines, lr)
+			return
+		}
+		engine := *flagEngine
+		switch {
+		case *flagHashLife > 0:
+			engine = "hashlife"
+		case *flagSparse:
+			engine = "sparse"
+		case *flagGPU:
+			engine = "gpu"
+		case *flagPacked:
+			engine = "bitset"
+		}
+		newEngine, ok := engines[engine]
+		if !ok {
+			log.Fatalf("unknown engine %q, want one of %s", engine, strings.Join(engineNames(), ", "))
+		}
+		if engine != "naive" && engine != "parallel" {
+			if !lifeLike {
+				log.Fatalf("engine %s needs a Life-like rule on the Moore neighbourhood", engine)
+			}
+			if set := setFlags(worldFlags); len(set) > 0 {
+				log.Fatalf("engine %s can't run with %s, which only the naive and parallel engines support", engine, strings.Join(set, ", "))
+			}
+			e, err := newEngine(width, height, cells, lr, boundary)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if h, ok := e.(*HashLifeWorld); ok && *flagHashLife > 0 {
+				h.Step = *flagHashLife
+			}
+			sim = e
+			break
+		}
+		w := NewWorld(width, height, cells)
+		if *flagForestFire != "" {
+			spec := *flagForestFire
+			if spec == "default" {
+				spec = ""
+			}
+			params, err := ParseForestFireParams(spec)
+			if err != nil {
+				log.Fatal(err)
+			}
+			rule = NewForestFireRule(params, w.Rand())
+		}
+		if *flagTemperature > 0 {
+			rule = NewStochasticRule(rule, *flagTemperature, w.Rand())
+		}
+		w.SetRule(rule)
+		w.Neighbourhood = nb
+		w.seedStates()
+		w.Boundary = boundary
+		if *flagZones != "" {
+			var zones []Rule
+			for _, s := range strings.Split(*flagZones, ",") {
+				zr, err := LookupRule(s)
+				if err != nil {
+					log.Fatal(err)
+				}
+				zones = append(zones, zr)
+			}
+			if err := w.SetZoneRules(zones); err != nil {
+				log.Fatal(err)
+			}
+		}
+		w.Radius = *flagRadius
+		w.Noise = *flagNoise
+		w.MaxAge = *flagMaxAge
+		w.MutateEvery = *flagMutateEvery
+		w.AntSteps = *flagAntSpeed
+		w.Workers = *flagWorkers
+		if engine == "naive" {
+			w.Workers = 1
+			w.FullUpdates = true
+		}
+		w.IncrementalCounts = *flagIncremental
+		w.Metrics = *flagMetrics
+		if *flagAnts > 0 {
+			turmite, err := LoadTurmite(*flagTurmite)
+			if err != nil {
+				log.Fatal(err)
+			}
+			w.addRandomTurmites(turmite, *flagAnts)
+		}
+		sim = w
+	}
+	if *flagBench > 0 {
+		if _, ok := sim.(*GPUWorld); ok {
+			log.Fatal("-bench can't run a -gpu world without a window")
+		}
+		fmt.Printf("%T: %v\n", sim, runBenchmark(sim, *flagBench))
+		return
+	}
+	r := NewRenderer(sim, gg.NewContext(screenWidth/cellSize, screenHeight/cellSize))
+	r.cellSize = cellSize
+	r.resetCamera()
+	if r.theme, err = LookupTheme(*flagTheme); err != nil {
+		log.Fatal(err)
+	}
+	r.ageColors = *flagAgeColors
+	r.trailFrames = *flagTrail
+	r.heatmap, r.heatWindow = *flagHeatmap, *flagHeatWindow
+	r.neighbourView = *flagNeighbourView
+	if err := r.post.enable(*flagPost); err != nil {
+		log.Fatal(err)
+	}
+	r.post.bloom.Intensity = *flagBloom
+	r.gridLines = *flagGridLines
+	r.screenshotDir = *flagScreenshots
+	if _, ok := gifPalettes[*flagGIFPalette]; !ok {
+		log.Fatalf("unknown GIF palette %q, want one of %s", *flagGIFPalette, strings.Join(gifPaletteNames(), ", "))
+	}
+	if _, ok := clipFormats[*flagClipFormat]; !ok {
+		log.Fatalf("unknown clip format %q, want one of %s", *flagClipFormat, strings.Join(clipFormatNames(), ", "))
+	}
+	if *flagClipSkip < 0 || *flagGIFColors < 2 || *flagGIFColors > 256 {
+		log.Fatal("-clip-skip must not be negative, and -gif-colors must be between 2 and 256")
+	}
+	if _, ok := videoFormats[*flagVideoFormat]; !ok {
+		log.Fatalf("unknown video format %q, want one of %s", *flagVideoFormat, strings.Join(videoFormatNames(), ", "))
+	}
+	r.video = videoRecorder{FFmpeg: *flagFFmpeg, Format: *flagVideoFormat}
+	r.clip = clipRecorder{Skip: *flagClipSkip, Format: *flagClipFormat, GIFPalette: *flagGIFPalette, GIFColors: *flagGIFColors}
+	r.history = historyStack{shown: *flagHistory, depth: *flagHistoryDepth}
+	if w, ok := sim.(*World); ok && *flagAnimate {
+		r.animate(w)
+	}
+	if *flagSprite != "" {
+		if r.spriteSrc, err = loadSprite(*flagSprite); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *flagTPS > 0 {
+		r.tps = *flagTPS
+	}
+	r.setTiming(*flagTPS, *flagVsync)
+	if w, ok := sim.(*World); ok && r.heatmap {
+		w.HeatWindow = r.heatWindow
+	}
+	r.pauseOnCycle = *flagPauseOnCycle
+	if r.onSettle, err = ParseSettleAction(*flagOnSettle); err != nil {
+		log.Fatal(err)
+	}
+
+	if *flagExport != "" {
+		if _, ok := sim.(*GPUWorld); ok {
+			log.Fatal("-export can't run a -gpu world without a window")
+		}
+		scale := *flagExportScale
+		if scale <= 0 {
+			scale = cellSize
+		}
+		if err := exportFrames(*flagExport, sim, r, *flagExportTicks, *flagExportEvery, scale); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *flagStream != "" {
+		if _, ok := sim.(*GPUWorld); ok {
+			log.Fatal("-stream can't run a -gpu world without a window")
+		}
+		if err := runStream(*flagStream, sim, r); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if headless {
+		log.Fatal("this build can't open a window, only -bench, -export and -stream run in it")
+	}
 
 	ch := make(chan struct{})
 
 	StartRenderingLoop(r, ch)
-	RunWorldUpdateLoop(w, r, ch)
+	RunWorldUpdateLoop(sim, r, ch)
 }
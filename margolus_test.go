@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+// TestBlockRuleStillStats checks that a pattern left alone by a block rule
+// counts no births and deaths, under either phase of the partition.
+func TestBlockRuleStillStats(t *testing.T) {
+	w := NewWorld(16, 16, 0)
+	w.SetRule(BBM)
+	w.Boundary = BoundaryWrap
+	// Two full rows are a wall: whole blocks on one phase, and pairs of
+	// cells side by side on the other.
+	for x := 0; x < 16; x++ {
+		w.Set(x, 6, true)
+		w.Set(x, 7, true)
+	}
+	for gen := 1; gen <= 4; gen++ {
+		w.Step(1)
+		s := w.Stats()
+		if s.Births != 0 || s.Deaths != 0 || s.Population != 32 {
+			t.Fatalf("generation %d: %d births, %d deaths and population %d, want 0, 0 and 32", gen, s.Births, s.Deaths, s.Population)
+		}
+	}
+}
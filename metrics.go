@@ -0,0 +1,87 @@
+package main
+
+import "math"
+
+// updateMetrics computes the research metrics of Stats for the current
+// generation. Cells beyond the edges count as dead, whatever the boundary
+// mode.
+func (w *World) updateMetrics() {
+	w.entropy = w.neighbourhoodEntropy()
+	w.clustering = w.clusteringCoefficient()
+}
+
+// neighbourhoodEntropy returns the Shannon entropy, in bits, of the
+// distribution of the 512 live and dead patterns of the 3×3 blocks centred
+// on every cell. It is 0 for a uniform world and at most 9.
+func (w *World) neighbourhoodEntropy() float64 {
+	var counts [512]int
+	for y := 0; y < w.height; y++ {
+		// Slide the block along the row, shifting in one column at a time.
+		column := func(x int) int {
+			c := 0
+			for dy := -1; dy <= 1; dy++ {
+				c <<= 1
+				if w.Alive(x, y+dy) {
+					c |= 1
+				}
+			}
+			return c
+		}
+		block := column(-1)<<3 | column(0)
+		for x := 0; x < w.width; x++ {
+			block = (block<<3 | column(x+1)) & 511
+			counts[block]++
+		}
+	}
+	n := float64(len(w.area))
+	h := 0.0
+	for _, c := range counts {
+		if c > 0 {
+			p := float64(c) / n
+			h -= p * math.Log2(p)
+		}
+	}
+	return h
+}
+
+// clusteringCoefficient returns the average local clustering coefficient of
+// the graph joining every live cell to its live Moore neighbours: for each
+// live cell with at least two live neighbours, the fraction of pairs of
+// those neighbours that are neighbours of each other. It is 0 if no cell
+// has two live neighbours.
+func (w *World) clusteringCoefficient() float64 {
+	var neighbours [8][2]int
+	sum, nodes := 0.0, 0
+	for y := 0; y < w.height; y++ {
+		for x := 0; x < w.width; x++ {
+			if w.area[y*w.width+x] == Dead {
+				continue
+			}
+			k := 0
+			for _, o := range mooreOffsets {
+				if w.Alive(x+o[0], y+o[1]) {
+					neighbours[k] = o
+					k++
+				}
+			}
+			if k < 2 {
+				continue
+			}
+			links := 0
+			for i := 0; i < k; i++ {
+				for j := i + 1; j < k; j++ {
+					a, b := neighbours[i], neighbours[j]
+					if abs(a[0]-b[0]) <= 1 && abs(a[1]-b[1]) <= 1 {
+						links++
+					}
+				}
+			}
+			sum += float64(links) / float64(k*(k-1)/2)
+			nodes++
+		}
+	}
+	if nodes == 0 {
+		return 0
+	}
+	return sum / float64(nodes)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	// minimapSize is the length in pixels of the longer side of the
+	// minimap, and minimapMargin its distance from the screen corner.
+	minimapSize   = 128
+	minimapMargin = 8
+	// minimapEvery is how many changes to the simulation the minimap
+	// stays the same for, since downscaling the whole world adds up.
+	minimapEvery = 8
+)
+
+var (
+	minimapBackground = color.RGBA{0x10, 0x10, 0x10, 0xc0}
+	minimapViewport   = color.RGBA{0xff, 0xff, 0x00, 0xff}
+)
+
+// minimap is the overview of the whole world in the bottom-right corner of
+// the screen, with the part the camera shows outlined.
+type minimap struct {
+	hidden bool
+	// image is the downscaled world as it was at version.
+	image   *ebiten.Image
+	version int
+}
+
+// minimapRect returns where on the screen the minimap goes and how much
+// smaller than the world it is drawn. ok is false if there is no minimap:
+// it is hidden, or the simulation has no fixed bounds to show.
+func (r *Renderer) minimapRect() (rect image.Rectangle, scale float64, ok bool) {
+	if _, view := r.sim.(viewSimulation); view || r.minimap.hidden {
+		return image.Rectangle{}, 0, false
+	}
+	w, h := r.dc.Width(), r.dc.Height()
+	scale = float64(minimapSize) / float64(w)
+	if h > w {
+		scale = float64(minimapSize) / float64(h)
+	}
+	size := image.Pt(int(float64(w)*scale), int(float64(h)*scale))
+	max := r.screen.Sub(image.Pt(minimapMargin, minimapMargin))
+	return image.Rectangle{max.Sub(size), max}, scale, true
+}
+
+// handleMinimap toggles the minimap with the M key and centres the camera
+// on the point of the world clicked on it. It reports whether the left
+// mouse button is held on the minimap, so that it doesn't paint or drag
+// the world as well.
+func (r *Renderer) handleMinimap() bool {
+	if inpututil.IsKeyJustPressed(ebiten.KeyM) {
+		r.minimap.hidden = !r.minimap.hidden
+		// Don't show a stale picture when it reappears.
+		r.minimap.version = r.version - minimapEvery
+	}
+	rect, scale, ok := r.minimapRect()
+	x, y := ebiten.CursorPosition()
+	if !ok || !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) || !image.Pt(x, y).In(rect) {
+		return false
+	}
+	wx, wy := float64(x-rect.Min.X)/scale, float64(y-rect.Min.Y)/scale
+	r.camera.X = wx - float64(r.screen.X)/2/r.camera.Zoom
+	r.camera.Y = wy - float64(r.screen.Y)/2/r.camera.Zoom
+	return true
+}
+
+// drawMinimap draws the minimap over screen, refreshing its picture of the
+// world every minimapEvery changes.
+func (r *Renderer) drawMinimap(screen *ebiten.Image, p palette) {
+	rect, scale, ok := r.minimapRect()
+	if !ok {
+		return
+	}
+	m := &r.minimap
+	if m.image == nil || m.image.Bounds().Size() != rect.Size() || r.version-m.version >= minimapEvery {
+		m.image = resizedImage(m.image, rect.Dx(), rect.Dy())
+		m.image.Fill(minimapBackground)
+		var geo ebiten.GeoM
+		geo.Scale(scale, scale)
+		m.image.DrawImage(r.frame, &ebiten.DrawImageOptions{GeoM: geo, Filter: ebiten.FilterLinear})
+		if ss, ok := r.sim.(screenSimulation); ok {
+			ss.DrawScreen(m.image, p, geo)
+		}
+		m.version = r.version
+	}
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(float64(rect.Min.X), float64(rect.Min.Y))
+	screen.DrawImage(m.image, op)
+
+	// Outline the part of the world on the screen, clipped to the world.
+	x0, y0 := r.camera.ScreenToWorld(0, 0)
+	x1, y1 := r.camera.ScreenToWorld(float64(r.screen.X), float64(r.screen.Y))
+	view := image.Rect(int(x0*scale), int(y0*scale), int(x1*scale), int(y1*scale)).
+		Add(rect.Min).Intersect(rect)
+	if view.Empty() {
+		return
+	}
+	minX, minY := float64(view.Min.X), float64(view.Min.Y)
+	w, h := float64(view.Dx()), float64(view.Dy())
+	ebitenutil.DrawRect(screen, minX, minY, w, 1, minimapViewport)
+	ebitenutil.DrawRect(screen, minX, minY+h-1, w, 1, minimapViewport)
+	ebitenutil.DrawRect(screen, minX, minY, 1, h, minimapViewport)
+	ebitenutil.DrawRect(screen, minX+w-1, minY, 1, h, minimapViewport)
+}
@@ -0,0 +1,45 @@
+package main
+
+import "math/rand"
+
+// mutated returns a copy of r with one neighbour count, picked at random,
+// added to or removed from either the birth or the survival set. Counts
+// beyond the size of the rule's neighbourhood and birth on zero
+// neighbours, which makes the whole background flash, are never touched.
+func (r *LifeRule) mutated(rng *rand.Rand) *LifeRule {
+	m := *r
+	m.birth = append([]bool(nil), r.birth...)
+	m.survival = append([]bool(nil), r.survival...)
+	n := min(len(r.neighbourhood.offsets(0, 0)), len(r.birth)-1)
+	if rng.Intn(2) == 0 {
+		i := 1 + rng.Intn(n)
+		m.birth[i] = !m.birth[i]
+	} else {
+		i := rng.Intn(n + 1)
+		m.survival[i] = !m.survival[i]
+	}
+	return &m
+}
+
+// mutateRule advances the mutation clock and, every MutateEvery
+// generations, replaces a Life-like rule with a random mutation of it.
+func (w *World) mutateRule() {
+	if w.MutateEvery <= 0 {
+		return
+	}
+	lr, ok := w.rule.(*LifeRule)
+	if !ok {
+		return
+	}
+	w.sinceMutation++
+	if w.sinceMutation < w.MutateEvery {
+		return
+	}
+	w.sinceMutation = 0
+	w.rule = lr.mutated(w.rng)
+}
+
+// Rule returns the rule the world currently runs.
+func (w *World) Rule() Rule {
+	return w.rule
+}
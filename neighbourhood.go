@@ -0,0 +1,145 @@
+package main
+
+import "fmt"
+
+// Neighbourhood determines which cells count as neighbours of a cell.
+type Neighbourhood int
+
+const (
+	// Moore is the eight cells surrounding a square cell.
+	Moore Neighbourhood = iota
+	// Hexagonal is the six cells around a hexagonal cell. The world is laid
+	// out like a Hexago grid: columns of hexagons, with odd columns shifted
+	// up by half a cell.
+	Hexagonal
+	// VonNeumann is the four cells sharing an edge with a square cell.
+	VonNeumann
+	// Extended is the Moore neighbourhood of radius 2: the 24 other cells
+	// of the 5×5 square around a cell.
+	Extended
+	// TriangleEdge is the three cells sharing an edge with a triangular
+	// cell. Cell (x, y) of a triangular world points up if x+y is even and
+	// down otherwise.
+	TriangleEdge
+	// TriangleVertex is the twelve cells sharing an edge or a corner with a
+	// triangular cell.
+	TriangleVertex
+)
+
+var neighbourhoodNames = map[Neighbourhood]string{
+	Moore:      "moore",
+	Hexagonal:  "hex",
+	VonNeumann: "vonneumann",
+	Extended:   "extended",
+
+	TriangleEdge:   "tri3",
+	TriangleVertex: "tri12",
+}
+
+func (n Neighbourhood) String() string {
+	if name, ok := neighbourhoodNames[n]; ok {
+		return name
+	}
+	return fmt.Sprintf("Neighbourhood(%d)", int(n))
+}
+
+// ParseNeighbourhood returns the neighbourhood with the given name, as
+// printed by Neighbourhood.String.
+func ParseNeighbourhood(s string) (Neighbourhood, error) {
+	for n, name := range neighbourhoodNames {
+		if name == s {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown neighbourhood %q", s)
+}
+
+// mooreOffsets are the Moore neighbours in row-major order, starting at the
+// top-left neighbour.
+var mooreOffsets = [][2]int{
+	{-1, -1}, {0, -1}, {1, -1},
+	{-1, 0}, {1, 0},
+	{-1, 1}, {0, 1}, {1, 1},
+}
+
+// hexOffsets are the hexagonal neighbours clockwise from the one above, for
+// even and odd columns respectively.
+var hexOffsets = [2][][2]int{
+	{{0, -1}, {1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}},
+	{{0, -1}, {1, -1}, {1, 0}, {0, 1}, {-1, 0}, {-1, -1}},
+}
+
+// vonNeumannOffsets are the von Neumann neighbours in row-major order.
+var vonNeumannOffsets = [][2]int{{0, -1}, {-1, 0}, {1, 0}, {0, 1}}
+
+// extendedOffsets are the radius 2 Moore neighbours in row-major order.
+var extendedOffsets = func() [][2]int {
+	var o [][2]int
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			if dx != 0 || dy != 0 {
+				o = append(o, [2]int{dx, dy})
+			}
+		}
+	}
+	return o
+}()
+
+// triangleEdgeOffsets are the edge neighbours of up- and down-pointing
+// triangles respectively, in row-major order.
+var triangleEdgeOffsets = [2][][2]int{
+	{{-1, 0}, {1, 0}, {0, 1}},
+	{{0, -1}, {-1, 0}, {1, 0}},
+}
+
+// triangleVertexOffsets are the edge and corner neighbours of up- and
+// down-pointing triangles respectively, in row-major order. A triangle
+// touches three cells in the row on its pointed side and five on its flat
+// side.
+var triangleVertexOffsets = [2][][2]int{
+	{
+		{-1, -1}, {0, -1}, {1, -1},
+		{-2, 0}, {-1, 0}, {1, 0}, {2, 0},
+		{-2, 1}, {-1, 1}, {0, 1}, {1, 1}, {2, 1},
+	},
+	{
+		{-2, -1}, {-1, -1}, {0, -1}, {1, -1}, {2, -1},
+		{-2, 0}, {-1, 0}, {1, 0}, {2, 0},
+		{-1, 1}, {0, 1}, {1, 1},
+	},
+}
+
+// grid returns the grid geometry the neighbourhood is defined on.
+func (n Neighbourhood) grid() Grid {
+	switch n {
+	case Hexagonal:
+		return HexGrid
+	case TriangleEdge, TriangleVertex:
+		return TriangleGrid
+	}
+	return SquareGrid
+}
+
+// offsets returns the neighbour offsets of the cell at (x, y).
+func (n Neighbourhood) offsets(x, y int) [][2]int {
+	switch n {
+	case Hexagonal:
+		return hexOffsets[x&1]
+	case TriangleEdge:
+		return triangleEdgeOffsets[(x+y)&1]
+	case TriangleVertex:
+		return triangleVertexOffsets[(x+y)&1]
+	case VonNeumann:
+		return vonNeumannOffsets
+	case Extended:
+		return extendedOffsets
+	}
+	return mooreOffsets
+}
+
+// neighbourhoodRule is implemented by rules whose rulestring names the
+// neighbourhood they are meant for, such as hexagonal "B2/S34H" or von
+// Neumann "B2/S013V".
+type neighbourhoodRule interface {
+	Neighbourhood() Neighbourhood
+}
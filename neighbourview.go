@@ -0,0 +1,96 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// deadTint is how much of the color of its neighbour count a dead cell is
+// drawn with in the neighbour view, out of 256. Live cells get all of it.
+const deadTint = 96
+
+// NeighbourCounts appends the number of live neighbours of every cell in
+// the current generation to dst, in row-major order, and returns it. The
+// neighbours are those the world's Neighbourhood and Boundary give the
+// rule; the larger neighbourhoods of Radius and of counting rules are not
+// taken into account.
+func (w *World) NeighbourCounts(dst []uint8) []uint8 {
+	var neighbours []State
+	for y := 0; y < w.height; y++ {
+		for x := 0; x < w.width; x++ {
+			neighbours = neighbourStates(neighbours[:0], w.area, w.width, w.height, x, y, w.Boundary, w.Neighbourhood)
+			n := 0
+			for _, s := range neighbours {
+				if s == Alive {
+					n++
+				}
+			}
+			dst = append(dst, uint8(n))
+		}
+	}
+	return dst
+}
+
+// neighbourColors returns the tint of every neighbour count from 0 to max,
+// running from blue for few live neighbours to red for all of them.
+func neighbourColors(max int) []color.RGBA {
+	colors := make([]color.RGBA, max+1)
+	for n := range colors {
+		colors[n] = color.RGBAModel.Convert(hsv(240*(1-float64(n)/float64(max)), 0.8, 0.9)).(color.RGBA)
+	}
+	return colors
+}
+
+// drawNeighbourPixels is the neighbour view of the pixel path: every cell
+// of w with live neighbours, dead or alive, is tinted by how many it has,
+// so that the rule can be read off the screen. Live cells are drawn in the
+// full tint and dead ones blended over the background, and cells without
+// live neighbours are drawn as usual. Only square grids without zones are
+// drawn this way.
+func (r *Renderer) drawNeighbourPixels(w *World, img *image.RGBA, p palette) bool {
+	if w.Grid() != SquareGrid || w.zones != nil {
+		return false
+	}
+	r.neighbours = w.NeighbourCounts(r.neighbours[:0])
+	tints := neighbourColors(len(w.Neighbourhood.offsets(0, 0)))
+	colors := p.rgbaTable()
+	b := img.Rect.Intersect(image.Rect(0, 0, w.width, w.height))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := w.area[y*w.width : (y+1)*w.width]
+		counts := r.neighbours[y*w.width : (y+1)*w.width]
+		pix := img.Pix[img.PixOffset(0, y):]
+		for x := b.Min.X; x < b.Max.X; x++ {
+			s, n := row[x], int(counts[x])
+			var c color.RGBA
+			switch {
+			case n > 0 && s == Alive:
+				c = tints[n]
+			case n > 0:
+				bg := colors[s]
+				if s == Dead {
+					bg = color.RGBA{pix[4*x], pix[4*x+1], pix[4*x+2], pix[4*x+3]}
+				}
+				t := tints[n]
+				c = color.RGBA{fade(t.R, bg.R, deadTint), fade(t.G, bg.G, deadTint), fade(t.B, bg.B, deadTint), fade(t.A, bg.A, deadTint)}
+			case s != Dead:
+				c = colors[s]
+			default:
+				continue
+			}
+			pix[4*x], pix[4*x+1], pix[4*x+2], pix[4*x+3] = c.R, c.G, c.B, c.A
+		}
+	}
+	w.drawFlagPixels(img)
+	return true
+}
+
+// handleNeighbourView toggles the neighbour view with the N key.
+func (r *Renderer) handleNeighbourView() {
+	if _, ok := r.sim.(*World); ok && inpututil.IsKeyJustPressed(ebiten.KeyN) {
+		r.neighbourView = !r.neighbourView
+		r.redraw()
+	}
+}
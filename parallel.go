@@ -0,0 +1,84 @@
+package main
+
+import "sync"
+
+// workerPool is a fixed set of goroutines that run the bands of a parallel
+// update.
+type workerPool struct {
+	size int
+	jobs chan func()
+}
+
+// newWorkerPool starts a pool of size goroutines.
+func newWorkerPool(size int) *workerPool {
+	p := &workerPool{size: size, jobs: make(chan func())}
+	for i := 0; i < size; i++ {
+		go func() {
+			for job := range p.jobs {
+				job()
+			}
+		}()
+	}
+	return p
+}
+
+// run calls job(0) to job(n-1) on the pool's goroutines and returns once all
+// of them have returned.
+func (p *workerPool) run(n int, job func(i int)) {
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		i := i
+		p.jobs <- func() {
+			defer wg.Done()
+			job(i)
+		}
+	}
+	wg.Wait()
+}
+
+// stop makes the pool's goroutines exit once they are idle.
+func (p *workerPool) stop() {
+	close(p.jobs)
+}
+
+// concurrent reports whether rule may be evaluated from several goroutines
+// at once. Rules drawing random numbers or caching results mutate shared
+// state on every call.
+func concurrent(rule Rule) bool {
+	switch rule.(type) {
+	case *StochasticRule, *ForestFireRule, *ExprRule, tickingRule:
+		return false
+	}
+	return true
+}
+
+// forEachBand splits the chunk rows of the world into bands and calls
+// update with the first and last chunk row of each, spreading the bands
+// over w.Workers goroutines when every rule in play allows it. update must
+// only write the cells of its own band.
+func (w *World) forEachBand(update func(cy0, cy1 int)) {
+	_, ch := chunkGrid(w.width, w.height)
+	n := min(w.Workers, ch)
+	if !concurrent(w.rule) {
+		n = 1
+	}
+	for _, r := range w.zoneRules {
+		if !concurrent(r) {
+			n = 1
+		}
+	}
+	if n < 2 {
+		update(0, ch)
+		return
+	}
+	if w.pool == nil || w.pool.size != n {
+		if w.pool != nil {
+			w.pool.stop()
+		}
+		w.pool = newWorkerPool(n)
+	}
+	w.pool.run(n, func(i int) {
+		update(i*ch/n, (i+1)*ch/n)
+	})
+}
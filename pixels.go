@@ -0,0 +1,93 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math/bits"
+)
+
+// pixelSimulation is implemented by simulations that can write their cells
+// straight into the frame buffer, one pixel per cell, which is much faster
+// than drawing them through gg.
+type pixelSimulation interface {
+	Simulation
+	// DrawPixels paints the cells that aren't dead into img, over the
+	// background already in it. It reports false, without touching img,
+	// if the simulation has to be drawn with Draw instead.
+	DrawPixels(img *image.RGBA, p palette) bool
+}
+
+// rgbaTable returns the colors of all states, premultiplied like the pixels
+// of an image.RGBA.
+func (p palette) rgbaTable() *[256]color.RGBA {
+	var t [256]color.RGBA
+	for s := range t {
+		t[s] = color.RGBAModel.Convert(p.color(State(s))).(color.RGBA)
+	}
+	return &t
+}
+
+// putPixel sets the pixel at (x, y) of img to c, if it lies within img.
+func putPixel(img *image.RGBA, x, y int, c color.RGBA) {
+	if !(image.Point{x, y}).In(img.Rect) {
+		return
+	}
+	i := img.PixOffset(x, y)
+	img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+}
+
+// DrawPixels implements pixelSimulation for worlds on the square grid
+// without zones, whose tints are blended over the cells by gg.
+func (w *World) DrawPixels(img *image.RGBA, p palette) bool {
+	if w.Grid() != SquareGrid || w.zones != nil {
+		return false
+	}
+	colors := p.rgbaTable()
+	b := w.bounds.Intersect(img.Rect)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := w.area[y*w.width : (y+1)*w.width]
+		pix := img.Pix[img.PixOffset(0, y):]
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if s := row[x]; s != Dead {
+				c := colors[s]
+				pix[4*x], pix[4*x+1], pix[4*x+2], pix[4*x+3] = c.R, c.G, c.B, c.A
+			}
+		}
+	}
+	w.drawFlagPixels(img)
+	return true
+}
+
+// drawFlagPixels paints walls, immortal cells and turmites into img over
+// the cells, as drawCellFlags and drawTurmites do with gg.
+func (w *World) drawFlagPixels(img *image.RGBA) {
+	for i, f := range w.flags {
+		switch {
+		case f&CellWall != 0:
+			putPixel(img, i%w.width, i/w.width, wallColor)
+		case f&CellImmortal != 0:
+			putPixel(img, i%w.width, i/w.width, immortalColor)
+		}
+	}
+	for _, t := range w.turmites {
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				putPixel(img, t.X+dx, t.Y+dy, turmiteColor)
+			}
+		}
+	}
+}
+
+// DrawPixels implements pixelSimulation, skipping empty words.
+func (b *BitWorld) DrawPixels(img *image.RGBA, p palette) bool {
+	c := color.RGBAModel.Convert(p.color(Alive)).(color.RGBA)
+	for y := 0; y < b.height; y++ {
+		for i, w := range b.cells[y*b.stride : (y+1)*b.stride] {
+			for w != 0 {
+				putPixel(img, i*64+bits.TrailingZeros64(w), y, c)
+				w &= w - 1
+			}
+		}
+	}
+	return true
+}
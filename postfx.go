@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// crtShader darkens every other row of pixels like the scanlines of a CRT,
+// and the corners of the screen like the edges of its tube.
+const crtShader = `package main
+
+func Fragment(position vec4, texCoord vec2, color vec4) vec4 {
+	texel := 1 / imageSrcTextureSize()
+	origin, size := imageSrcRegionOnTexture()
+	uv := (texCoord - origin) / size
+	p := (texCoord - origin) / texel
+
+	c := imageSrc0UnsafeAt(texCoord)
+	scan := 1.0
+	if mod(floor(p.y), 2) == 1 {
+		scan = 0.65
+	}
+	d := uv - vec2(0.5)
+	vignette := 1 - 0.8*dot(d, d)
+	return vec4(c.rgb*scan*vignette, c.a)
+}
+`
+
+// chromaShader offsets the red and blue channels outwards from the centre
+// of the screen, like a cheap lens failing to focus every color at once.
+const chromaShader = `package main
+
+func Fragment(position vec4, texCoord vec2, color vec4) vec4 {
+	texel := 1 / imageSrcTextureSize()
+	origin, size := imageSrcRegionOnTexture()
+	uv := (texCoord - origin) / size
+
+	off := (uv - vec2(0.5)) * 4 * texel
+	r := imageSrc0At(texCoord + off)
+	c := imageSrc0UnsafeAt(texCoord)
+	b := imageSrc0At(texCoord - off)
+	return vec4(r.r, c.g, b.b, max(c.a, max(r.a, b.a)))
+}
+`
+
+// postEffect is one stage of the post-processing pipeline, applied to the
+// whole composed image of the world.
+type postEffect struct {
+	name string
+	// key toggles the effect at runtime.
+	key ebiten.Key
+	on  bool
+	// draw draws src through the effect onto dst, which is the same size
+	// and already cleared.
+	draw func(dst, src *ebiten.Image)
+}
+
+// shaderEffect returns the draw function of an effect that is a single
+// Kage shader, compiled the first time it is drawn.
+func shaderEffect(src string) func(dst, src *ebiten.Image) {
+	var shader *ebiten.Shader
+	return func(dst, img *ebiten.Image) {
+		if shader == nil {
+			s, err := ebiten.NewShader([]byte(src))
+			if err != nil {
+				panic(err)
+			}
+			shader = s
+		}
+		w, h := img.Size()
+		op := &ebiten.DrawRectShaderOptions{}
+		op.Images[0] = img
+		dst.DrawRectShader(w, h, shader, op)
+	}
+}
+
+// postProcessor runs the enabled effects one after the other over the
+// image of the world before it is presented. The world is composed into
+// images[0], and the effects write back and forth between the other two.
+type postProcessor struct {
+	effects []*postEffect
+	images  [3]*ebiten.Image
+	bloom   bloom
+}
+
+// newPostProcessor returns a pipeline of the built-in effects, all of them
+// off.
+func newPostProcessor() *postProcessor {
+	pp := &postProcessor{bloom: bloom{Intensity: 1}}
+	pp.effects = []*postEffect{
+		{name: "crt", key: ebiten.KeyF5, draw: shaderEffect(crtShader)},
+		{name: "bloom", key: ebiten.KeyF6, draw: pp.bloom.draw},
+		{name: "chroma", key: ebiten.KeyF7, draw: shaderEffect(chromaShader)},
+	}
+	return pp
+}
+
+// enable turns on the effects named in the comma-separated list names.
+func (pp *postProcessor) enable(names string) error {
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		e := pp.effect(name)
+		if e == nil {
+			return fmt.Errorf("unknown post-processing effect %q, want one of %s", name, strings.Join(pp.names(), ", "))
+		}
+		e.on = true
+	}
+	return nil
+}
+
+// effect returns the effect with the given name, or nil.
+func (pp *postProcessor) effect(name string) *postEffect {
+	for _, e := range pp.effects {
+		if e.name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// names returns the names of the effects in the order they are applied.
+func (pp *postProcessor) names() []string {
+	var names []string
+	for _, e := range pp.effects {
+		names = append(names, e.name)
+	}
+	return names
+}
+
+// active returns the enabled effects in the order they are applied.
+func (pp *postProcessor) active() []*postEffect {
+	var active []*postEffect
+	for _, e := range pp.effects {
+		if e.on {
+			active = append(active, e)
+		}
+	}
+	return active
+}
+
+// target returns the cleared image of the given size to compose the world
+// into before calling apply.
+func (pp *postProcessor) target(width, height int) *ebiten.Image {
+	for i, img := range pp.images {
+		pp.images[i] = resizedImage(img, width, height)
+	}
+	return pp.images[0]
+}
+
+// apply runs the enabled effects over the image returned by target and
+// draws the result onto dst, which must be the same size.
+func (pp *postProcessor) apply(dst *ebiten.Image) {
+	active := pp.active()
+	src := pp.images[0]
+	for i, e := range active {
+		out := dst
+		if i < len(active)-1 {
+			out = pp.images[1+i%2]
+			out.Clear()
+		}
+		e.draw(out, src)
+		src = out
+	}
+}
+
+// handlePostEffects toggles each post-processing effect with its key.
+func (r *Renderer) handlePostEffects() {
+	for _, e := range r.post.effects {
+		if inpututil.IsKeyJustPressed(e.key) {
+			e.on = !e.on
+		}
+	}
+}
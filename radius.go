@@ -0,0 +1,26 @@
+package main
+
+// radiusRule runs a Life-like rule over the Moore neighbourhood of a larger
+// radius, as selected by World.Radius.
+type radiusRule struct {
+	*LifeRule
+	radius int
+}
+
+// Radius implements countingRule.
+func (r radiusRule) Radius() int {
+	return r.radius
+}
+
+// NextCount implements countingRule. Counts above nine are written as a
+// list in the rulestring, like "B3,10..12/S2,3"; cells with more live
+// neighbours than the rule lists die.
+func (r radiusRule) NextCount(self State, count int) State {
+	if count >= len(r.birth) {
+		return Dead
+	}
+	if self == Alive && r.survival[count] || self != Alive && r.birth[count] {
+		return Alive
+	}
+	return Dead
+}
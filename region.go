@@ -0,0 +1,80 @@
+package main
+
+import "image"
+
+// Pattern is a rectangular block of cells lifted out of a world.
+type Pattern struct {
+	Width, Height int
+	// Cells holds the states of the cells in row-major order.
+	Cells []State
+}
+
+// At returns the state of the cell at (x, y) of the pattern.
+func (p *Pattern) At(x, y int) State {
+	return p.Cells[y*p.Width+x]
+}
+
+// PasteMode determines how a pasted pattern combines with the cells
+// beneath it.
+type PasteMode int
+
+const (
+	// PasteOverwrite replaces the cells beneath the pattern, dead ones
+	// included.
+	PasteOverwrite PasteMode = iota
+	// PasteOr only copies the pattern's live cells, leaving the cells
+	// beneath its dead ones alone.
+	PasteOr
+	// PasteXor toggles the cells beneath the pattern's live cells: dead
+	// ones take the pattern's state and the others die.
+	PasteXor
+)
+
+// region clips r to the world.
+func (w *World) region(r image.Rectangle) image.Rectangle {
+	return r.Canon().Intersect(image.Rect(0, 0, w.width, w.height))
+}
+
+// CopyRegion returns the cells within r, clipped to the world.
+func (w *World) CopyRegion(r image.Rectangle) *Pattern {
+	r = w.region(r)
+	p := &Pattern{Width: r.Dx(), Height: r.Dy(), Cells: make([]State, 0, r.Dx()*r.Dy())}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		p.Cells = append(p.Cells, w.area[y*w.width+r.Min.X:y*w.width+r.Max.X]...)
+	}
+	return p
+}
+
+// ClearRegion kills the cells within r.
+func (w *World) ClearRegion(r image.Rectangle) {
+	r = w.region(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			w.set(y*w.width+x, Dead)
+		}
+	}
+}
+
+// PasteRegion stamps p onto the world with its top-left corner at (x, y),
+// combining it with the cells beneath according to mode. The parts of the
+// pattern beyond the edges of the world are dropped.
+func (w *World) PasteRegion(p *Pattern, x, y int, mode PasteMode) {
+	r := w.region(image.Rect(x, y, x+p.Width, y+p.Height))
+	for wy := r.Min.Y; wy < r.Max.Y; wy++ {
+		for wx := r.Min.X; wx < r.Max.X; wx++ {
+			i := wy*w.width + wx
+			s := p.At(wx-x, wy-y)
+			switch {
+			case mode == PasteOverwrite:
+				w.set(i, s)
+			case s == Dead:
+			case mode == PasteOr:
+				w.set(i, s)
+			case mode == PasteXor && w.area[i] == Dead:
+				w.set(i, s)
+			case mode == PasteXor:
+				w.set(i, Dead)
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"image"
+)
+
+// Anchor is the point of the world that stays in place when it is resized.
+type Anchor int
+
+const (
+	AnchorTopLeft Anchor = iota
+	AnchorTopRight
+	AnchorBottomLeft
+	AnchorBottomRight
+	AnchorCenter
+)
+
+var anchorNames = map[Anchor]string{
+	AnchorTopLeft:     "top-left",
+	AnchorTopRight:    "top-right",
+	AnchorBottomLeft:  "bottom-left",
+	AnchorBottomRight: "bottom-right",
+	AnchorCenter:      "center",
+}
+
+func (a Anchor) String() string {
+	if name, ok := anchorNames[a]; ok {
+		return name
+	}
+	return fmt.Sprintf("Anchor(%d)", int(a))
+}
+
+// ParseAnchor returns the anchor with the given name, as printed by
+// Anchor.String.
+func ParseAnchor(s string) (Anchor, error) {
+	for a, name := range anchorNames {
+		if name == s {
+			return a, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown anchor %q", s)
+}
+
+// offset returns where the old top-left corner of a width×height world
+// lands when it is resized to newWidth×newHeight.
+func (a Anchor) offset(width, height, newWidth, newHeight int) image.Point {
+	dx, dy := newWidth-width, newHeight-height
+	switch a {
+	case AnchorTopRight:
+		return image.Pt(dx, 0)
+	case AnchorBottomLeft:
+		return image.Pt(0, dy)
+	case AnchorBottomRight:
+		return image.Pt(dx, dy)
+	case AnchorCenter:
+		return image.Pt(dx/2, dy/2)
+	}
+	return image.Point{}
+}
+
+// Resize grows or crops the world to newWidth×newHeight cells, keeping the
+// cells that still fit in place relative to w.Anchor. New cells are dead,
+// outside any zone and without flags, and turmites pushed off the world
+// wrap around to the other side.
+func (w *World) Resize(newWidth, newHeight int) {
+	off := w.Anchor.offset(w.width, w.height, newWidth, newHeight)
+	n := newWidth * newHeight
+	// move calls f for every cell kept, with its old and new index.
+	move := func(f func(from, to int)) {
+		kept := image.Rect(0, 0, w.width, w.height).Add(off).Intersect(image.Rect(0, 0, newWidth, newHeight))
+		for y := kept.Min.Y; y < kept.Max.Y; y++ {
+			for x := kept.Min.X; x < kept.Max.X; x++ {
+				f((y-off.Y)*w.width+x-off.X, y*newWidth+x)
+			}
+		}
+	}
+
+	area := make([]State, n)
+	move(func(from, to int) { area[to] = w.area[from] })
+	w.area = area
+	w.chunks.invalidate()
+	if w.ages != nil {
+		ages := make([]uint16, n)
+		move(func(from, to int) { ages[to] = w.ages[from] })
+		w.ages = ages
+	}
+	if w.flags != nil {
+		flags := make([]CellFlags, n)
+		move(func(from, to int) { flags[to] = w.flags[from] })
+		w.flags = flags
+	}
+	if w.zones != nil {
+		zones := make([]uint8, n)
+		move(func(from, to int) { zones[to] = w.zones[from] })
+		w.zones = zones
+	}
+	for _, t := range w.turmites {
+		t.X = ((t.X+off.X)%newWidth + newWidth) % newWidth
+		t.Y = ((t.Y+off.Y)%newHeight + newHeight) % newHeight
+	}
+	w.width, w.height = newWidth, newHeight
+	w.prev = w.prev[:0]
+
+	w.population = 0
+	for _, s := range w.area {
+		w.population += live(s)
+	}
+	w.updateBounds()
+	w.cycles.reset()
+	w.period = 0
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// State is the state of a single cell. Two-state rules only use Dead and
+// Alive; automata with more states define their own values above Alive.
+type State uint8
+
+const (
+	Dead State = iota
+	Alive
+	// Dying is the first decay state of Generations rules such as Brian's
+	// Brain: the cell was alive on the previous tick.
+	Dying
+)
+
+// Rule is a cellular automaton transition function. Next returns the state a
+// cell moves to given its own state and the states of its neighbours.
+// Implementations must not retain the neighbours slice.
+type Rule interface {
+	Next(self State, neighbours []State) State
+}
+
+// LifeRule is a Life-like rule: the set of neighbour counts that bring a dead
+// cell to life, and the set that keeps a live cell alive.
+type LifeRule struct {
+	// birth and survival are indexed by count. They are as long as each
+	// other, and hold at least mooreCounts counts and any larger ones the
+	// rulestring lists.
+	birth    []bool
+	survival []bool
+	// neighbourhood is the neighbourhood named by the rulestring.
+	neighbourhood Neighbourhood
+}
+
+var (
+	// Conway is the rule of Conway's Game of Life.
+	Conway = MustParseRule("B3/S23")
+	// HighLife is Conway's rule plus birth on six neighbours, which gives
+	// rise to a small self-replicating pattern.
+	HighLife = MustParseRule("B36/S23")
+	// Seeds has no survival condition at all: every live cell dies on the
+	// next tick, and dead cells with exactly two live neighbours are born.
+	Seeds = MustParseRule("B2/S")
+	// DayAndNight is symmetric under inverting every cell, so a pattern of
+	// dead cells in a live sea behaves exactly like its live counterpart.
+	DayAndNight = MustParseRule("B3678/S34678")
+	// HexLife is a hexagonal rule with a glider, run on six neighbours.
+	HexLife = MustParseRule("B2/S34H")
+	// Anneal is the twisted majority rule: a cell takes the majority state
+	// of its 3×3 block, except that 4 and 5 swap outcomes. Started from a
+	// half-full soup, the domains of either state slowly coarsen with
+	// smooth, shrinking boundaries.
+	Anneal = MustParseRule("B4678/S35678")
+	// Majority makes every cell take the majority state of its 3×3 block,
+	// itself included. Random soups quickly freeze into jagged domains.
+	Majority = MustParseRule("B5678/S45678")
+)
+
+// rulePresets are the built-in rules that can be selected by name.
+var rulePresets = map[string]Rule{
+	"conway":   Conway,
+	"highlife": HighLife,
+	"seeds":    Seeds,
+	"daynight": DayAndNight,
+	"hexlife":  HexLife,
+	"anneal":   Anneal,
+	"majority": Majority,
+
+	"immigration": Immigration,
+	"quadlife":    QuadLife,
+
+	"briansbrain": BriansBrain,
+	"wireworld":   Wireworld,
+	"bugs":        Bugs,
+	"bbm":         BBM,
+	"critters":    Critters,
+	"cyclic":      CyclicSpirals,
+	"static":      Static,
+}
+
+// BriansBrain is a three-state Generations rule in which live cells always
+// spend one tick dying before they become dead, and dead cells with exactly
+// two live neighbours are born.
+var BriansBrain = MustParseGenerationsRule("/2/3")
+
+// Static leaves every cell as it is. It is meant for worlds driven entirely
+// by agents, such as Langton's ant.
+var Static Rule = static{}
+
+type static struct{}
+
+// Next implements Rule.
+func (static) Next(self State, neighbours []State) State {
+	return self
+}
+
+// Wireworld states. Electron heads reuse Alive and tails reuse Dying, so a
+// random soup of live cells simply burns out and leaves an empty canvas.
+const (
+	WireEmpty     = Dead
+	WireHead      = Alive
+	WireTail      = Dying
+	WireConductor = Dying + 1
+)
+
+// Wireworld simulates electrons travelling along conductors: heads become
+// tails, tails become conductor again, and a conductor becomes a head when
+// one or two of its neighbours are heads.
+var Wireworld Rule = wireworld{}
+
+type wireworld struct{}
+
+// Next implements Rule.
+func (wireworld) Next(self State, neighbours []State) State {
+	switch self {
+	case WireHead:
+		return WireTail
+	case WireTail:
+		return WireConductor
+	case WireConductor:
+		n := 0
+		for _, s := range neighbours {
+			if s == WireHead {
+				n++
+			}
+		}
+		if n == 1 || n == 2 {
+			return WireHead
+		}
+		return WireConductor
+	}
+	return WireEmpty
+}
+
+// presetNames returns the names of the built-in rules in sorted order.
+func presetNames() []string {
+	names := make([]string, 0, len(rulePresets))
+	for name := range rulePresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupRule returns the built-in rule with the given name, or parses s as a
+// rulestring if there is no such preset. Rulestrings with three parts are
+// parsed as Generations rules, ones starting with "MS,D" as Margolus block
+// rules, other comma-separated ones without slashes as Larger than Life, four slash-separated
+// fields starting with "R" as cyclic rules, and ones with neighbourhood
+// letters in Hensel notation.
+func LookupRule(s string) (Rule, error) {
+	if r, ok := rulePresets[strings.ToLower(s)]; ok {
+		return r, nil
+	}
+	if hasPrefixFold(strings.TrimSpace(s), "MS,D") {
+		return ParseBlockRule(s)
+	}
+	if hasPrefixFold(strings.TrimSpace(s), "W") {
+		return ParseWeightedRule(s)
+	}
+	if strings.Contains(s, ",") && !strings.Contains(s, "/") {
+		return ParseLtLRule(s)
+	}
+	if hasPrefixFold(strings.TrimSpace(s), "R") && strings.Count(s, "/") == 3 {
+		return ParseCyclicRule(s)
+	}
+	if strings.Count(s, "/") == 2 {
+		return ParseGenerationsRule(s)
+	}
+	if strings.ContainsAny(strings.ToLower(s), "cekainyqjrtwz-") {
+		return ParseHenselRule(s)
+	}
+	return ParseRule(s)
+}
+
+// ParseRule parses a Golly-style rulestring such as "B3/S23" or "B36/S23".
+// The legacy "S/B" form without letters (e.g. "23/3") is accepted as well. A
+// trailing "H", as in "B2/S34H", makes it a rule for hexagonal worlds, and a
+// trailing "V" one for the von Neumann neighbourhood.
+//
+// For neighbourhoods with more than nine neighbours, like the extended,
+// triangular and larger radius ones, the counts can also be given as a
+// comma-separated list of counts and ranges of counts, as in
+// "B3,10..12/S2,3".
+func ParseRule(s string) (*LifeRule, error) {
+	spec := strings.TrimSpace(s)
+	r := &LifeRule{}
+	maxCount := maxLifeCount
+	switch {
+	case hasSuffixFold(spec, "H"):
+		spec = spec[:len(spec)-1]
+		r.neighbourhood = Hexagonal
+		maxCount = 6
+	case hasSuffixFold(spec, "V"):
+		spec = spec[:len(spec)-1]
+		r.neighbourhood = VonNeumann
+		maxCount = 4
+	}
+	parts := strings.Split(spec, "/")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("rule %q: expected two parts separated by '/'", s)
+	}
+
+	var birth, survival string
+	switch {
+	case hasPrefixFold(parts[0], "B") && hasPrefixFold(parts[1], "S"):
+		birth, survival = parts[0][1:], parts[1][1:]
+	case hasPrefixFold(parts[0], "S") && hasPrefixFold(parts[1], "B"):
+		survival, birth = parts[0][1:], parts[1][1:]
+	default:
+		survival, birth = parts[0], parts[1]
+	}
+
+	var err error
+	if r.birth, err = parseCounts(birth, maxCount); err != nil {
+		return nil, fmt.Errorf("rule %q: birth: %v", s, err)
+	}
+	if r.survival, err = parseCounts(survival, maxCount); err != nil {
+		return nil, fmt.Errorf("rule %q: survival: %v", s, err)
+	}
+	for len(r.birth) < len(r.survival) {
+		r.birth = append(r.birth, false)
+	}
+	for len(r.survival) < len(r.birth) {
+		r.survival = append(r.survival, false)
+	}
+	return r, nil
+}
+
+// MustParseRule is like ParseRule but panics if the rulestring is invalid.
+func MustParseRule(s string) *LifeRule {
+	r, err := ParseRule(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+func hasSuffixFold(s, suffix string) bool {
+	return len(s) >= len(suffix) && strings.EqualFold(s[len(s)-len(suffix):], suffix)
+}
+
+// mooreCounts is the number of neighbour counts the Moore neighbourhood
+// reaches, 0 to 8. The tables of a LifeRule always hold at least as many, so
+// that the engines for the Moore neighbourhood can index them by any count.
+const mooreCounts = 9
+
+// maxLifeCount bounds the neighbour counts of rules that don't name their
+// neighbourhood: the number of neighbours of the largest radius a Larger
+// than Life rule can have.
+const maxLifeCount = (2*maxRadius+1)*(2*maxRadius+1) - 1
+
+// parseCounts returns the set of neighbour counts listed in s, indexed by
+// count, with room for at least mooreCounts counts. s is either a string of
+// digits, one count each, or a comma-separated list of counts and ranges of
+// counts. No count may exceed maxCount.
+func parseCounts(s string, maxCount int) ([]bool, error) {
+	var counts [][2]int
+	if strings.ContainsAny(s, ",.") {
+		for _, f := range strings.Split(s, ",") {
+			lo, hi, err := parseRange(f)
+			if err != nil {
+				return nil, fmt.Errorf("invalid neighbour counts %q", f)
+			}
+			counts = append(counts, [2]int{lo, hi})
+		}
+	} else {
+		for _, c := range s {
+			if c < '0' || c > '9' {
+				return nil, fmt.Errorf("invalid neighbour count %q", c)
+			}
+			counts = append(counts, [2]int{int(c - '0'), int(c - '0')})
+		}
+	}
+	set := make([]bool, mooreCounts)
+	for _, c := range counts {
+		for _, n := range c {
+			if n < 0 || n > maxCount {
+				return nil, fmt.Errorf("neighbour count %d out of range", n)
+			}
+		}
+		for len(set) <= c[1] {
+			set = append(set, false)
+		}
+		for n := c[0]; n <= c[1]; n++ {
+			if set[n] {
+				return nil, fmt.Errorf("duplicate neighbour count %d", n)
+			}
+			set[n] = true
+		}
+	}
+	return set, nil
+}
+
+// Next implements Rule. Counts beyond the largest one the rulestring
+// lists never satisfy the rule.
+func (r *LifeRule) Next(self State, neighbours []State) State {
+	n := 0
+	for _, s := range neighbours {
+		if s == Alive {
+			n++
+		}
+	}
+	if n >= len(r.birth) {
+		return Dead
+	}
+	if self == Alive && r.survival[n] || self != Alive && r.birth[n] {
+		return Alive
+	}
+	return Dead
+}
+
+// Neighbourhood implements neighbourhoodRule.
+func (r *LifeRule) Neighbourhood() Neighbourhood {
+	return r.neighbourhood
+}
+
+// mortal reports whether live cells never survive, as in Seeds. The next
+// state of a live cell then doesn't depend on its neighbours.
+func (r *LifeRule) mortal() bool {
+	for _, ok := range r.survival {
+		if ok {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the rule in B/S notation.
+func (r *LifeRule) String() string {
+	var sb strings.Builder
+	sb.WriteByte('B')
+	sb.WriteString(formatCounts(r.birth))
+	sb.WriteString("/S")
+	sb.WriteString(formatCounts(r.survival))
+	switch r.neighbourhood {
+	case Hexagonal:
+		sb.WriteByte('H')
+	case VonNeumann:
+		sb.WriteByte('V')
+	}
+	return sb.String()
+}
+
+// formatCounts writes the counts in set the way parseCounts reads them: as
+// digits if they are all below ten, and otherwise as a comma-separated list
+// in which runs of consecutive counts are written as ranges. A lone count
+// above nine is written as a range of one, so that it isn't read as digits.
+func formatCounts(set []bool) string {
+	var runs [][2]int
+	wide := false
+	for n, ok := range set {
+		if !ok {
+			continue
+		}
+		wide = wide || n > 9
+		if len(runs) > 0 && runs[len(runs)-1][1] == n-1 {
+			runs[len(runs)-1][1] = n
+		} else {
+			runs = append(runs, [2]int{n, n})
+		}
+	}
+	var sb strings.Builder
+	for i, run := range runs {
+		if !wide {
+			for n := run[0]; n <= run[1]; n++ {
+				sb.WriteByte(byte('0' + n))
+			}
+			continue
+		}
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(strconv.Itoa(run[0]))
+		if run[1] > run[0] || len(runs) == 1 {
+			sb.WriteString("..")
+			sb.WriteString(strconv.Itoa(run[1]))
+		}
+	}
+	return sb.String()
+}
+
+// nextAlive returns whether a cell is alive in the next generation given
+// whether it is alive now and its number of live neighbours.
+func (r *LifeRule) nextAlive(alive bool, n int) bool {
+	if n >= len(r.birth) {
+		return false
+	}
+	if alive {
+		return r.survival[n]
+	}
+	return r.birth[n]
+}
@@ -0,0 +1,124 @@
+package main
+
+import "testing"
+
+// TestParseRuleCounts checks that rulestrings with counts above nine,
+// which the neighbourhoods with more neighbours than Moore reach, are read
+// and written back.
+func TestParseRuleCounts(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"B3/S23", "B3/S23"},
+		{"B36/S23H", "B36/S23H"},
+		{"B9/S", "B9/S"},
+		{"B3,10..12/S2,3", "B3,10..12/S23"},
+		{"B24..24/S", "B24..24/S"},
+		{"B34..45/S34..58", "B34..45/S34..58"},
+	}
+	for _, tt := range tests {
+		r, err := ParseRule(tt.in)
+		if err != nil {
+			t.Errorf("ParseRule(%q): %v", tt.in, err)
+			continue
+		}
+		if got := r.String(); got != tt.want {
+			t.Errorf("ParseRule(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+		if _, err := ParseRule(r.String()); err != nil {
+			t.Errorf("ParseRule(%q) doesn't read back: %v", r.String(), err)
+		}
+	}
+	for _, s := range []string{"B7/S23H", "B3/S5V", "B3,,4/S", "B3/S2,2"} {
+		if _, err := ParseRule(s); err == nil {
+			t.Errorf("ParseRule(%q) succeeded", s)
+		}
+	}
+	if r, err := LookupRule("B3,10/S23"); err != nil {
+		t.Errorf("LookupRule: %v", err)
+	} else if _, ok := r.(*LifeRule); !ok {
+		t.Errorf("LookupRule(%q) = %T, want a Life-like rule", "B3,10/S23", r)
+	}
+}
+
+// TestExtendedCounts checks that a rule over the extended neighbourhood
+// sees counts above eight.
+func TestExtendedCounts(t *testing.T) {
+	w := NewWorld(9, 9, 0)
+	w.SetRule(MustParseRule("B24..24/S14..14"))
+	w.Neighbourhood = Extended
+	for y := 2; y <= 6; y++ {
+		for x := 2; x <= 6; x++ {
+			if x != 4 || y != 4 {
+				w.Set(x, y, true)
+			}
+		}
+	}
+	// (4, 4) has all 24 neighbours alive, (3, 3) has 14 and (2, 2)
+	// only 7.
+	w.Step(1)
+	if !w.Alive(4, 4) {
+		t.Error("the cell with 24 live neighbours wasn't born")
+	}
+	if !w.Alive(3, 3) {
+		t.Error("the cell with 14 live neighbours died")
+	}
+	if w.Alive(2, 2) {
+		t.Error("a cell with 7 live neighbours survived")
+	}
+}
+
+// TestTriangleCounts checks that a rule over the twelve neighbours of a
+// triangular cell sees counts above eight.
+func TestTriangleCounts(t *testing.T) {
+	w := NewWorld(10, 10, 0)
+	w.SetRule(MustParseRule("B12..12/S9..12"))
+	w.Neighbourhood = TriangleVertex
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			if x != 5 || y != 5 {
+				w.Set(x, y, true)
+			}
+		}
+	}
+	// (5, 5) has all 12 neighbours alive and (1, 1) 10, but the corner
+	// (0, 0) only 5.
+	w.Step(1)
+	if !w.Alive(5, 5) {
+		t.Error("the cell with 12 live neighbours wasn't born")
+	}
+	if !w.Alive(1, 1) {
+		t.Error("the cell with 10 live neighbours died")
+	}
+	if w.Alive(0, 0) {
+		t.Error("a cell with 5 live neighbours survived")
+	}
+}
+
+// TestRadiusCounts checks that a rule run at radius 2 sees counts above
+// eight, and agrees with the same rule run over the extended
+// neighbourhood, which is the radius 2 Moore neighbourhood gathered cell
+// by cell.
+func TestRadiusCounts(t *testing.T) {
+	rule := MustParseRule("B10..13/S8..15")
+	SetSeed(1)
+	counted := NewWorld(40, 30, 500)
+	SetSeed(1)
+	gathered := NewWorld(40, 30, 500)
+	counted.SetRule(rule)
+	counted.Radius = 2
+	gathered.SetRule(rule)
+	gathered.Neighbourhood = Extended
+	for gen := 1; gen <= 10; gen++ {
+		counted.Step(1)
+		gathered.Step(1)
+		for i := range counted.area {
+			if counted.area[i] != gathered.area[i] {
+				t.Fatalf("generation %d: cell %d is %v counted but %v gathered", gen, i, counted.area[i], gathered.area[i])
+			}
+		}
+	}
+	if counted.Population() == 0 {
+		t.Fatal("the world died out")
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+// sandpileThreshold is the number of grains at which a cell topples.
+const sandpileThreshold = 4
+
+// sandpilePalette colors the stable heights 0 to 3.
+var sandpilePalette = palette{
+	color.Transparent,
+	color.RGBA{0x1f, 0x4e, 0x9c, 0xff},
+	color.RGBA{0xf2, 0xc1, 0x4e, 0xff},
+	color.RGBA{0xd6, 0x3c, 0x2f, 0xff},
+}
+
+// SandpileWorld is the Abelian sandpile model. Every cell holds a number of
+// grains; a cell with four or more topples, passing one grain to each of
+// its von Neumann neighbours. Grains toppling over the edge of the world
+// are lost, so every cascade eventually comes to rest.
+type SandpileWorld struct {
+	grains []int
+	width  int
+	height int
+	scale  int
+	// unstable lists cells that may have reached the threshold.
+	unstable []int
+
+	// Drip is the number of grains dropped on the centre cell every tick.
+	Drip int
+}
+
+// NewSandpileWorld creates an empty sandpile of width×height cells, each
+// drawn as a scale×scale square.
+func NewSandpileWorld(width, height, scale int) *SandpileWorld {
+	return &SandpileWorld{
+		grains: make([]int, width*height),
+		width:  width,
+		height: height,
+		scale:  scale,
+	}
+}
+
+// Scale returns the size in pixels of a cell.
+func (s *SandpileWorld) Scale() int {
+	return s.scale
+}
+
+// Drop adds n grains to cell (x, y). The cascade it may start runs on the
+// next Update.
+func (s *SandpileWorld) Drop(x, y, n int) {
+	if x < 0 || y < 0 || x >= s.width || y >= s.height {
+		return
+	}
+	i := y*s.width + x
+	s.grains[i] += n
+	s.unstable = append(s.unstable, i)
+}
+
+// Update drops Drip grains on the centre and topples cells until the pile
+// is stable again.
+func (s *SandpileWorld) Update(t *time.Time) {
+	if s.Drip > 0 {
+		s.Drop(s.width/2, s.height/2, s.Drip)
+	}
+	for len(s.unstable) > 0 {
+		i := s.unstable[len(s.unstable)-1]
+		s.unstable = s.unstable[:len(s.unstable)-1]
+		g := s.grains[i]
+		if g < sandpileThreshold {
+			continue
+		}
+		// Topple as often as the cell allows in one go.
+		n := g / sandpileThreshold
+		s.grains[i] = g % sandpileThreshold
+		x, y := i%s.width, i/s.width
+		for _, o := range vonNeumannOffsets {
+			x2, y2 := x+o[0], y+o[1]
+			if x2 < 0 || y2 < 0 || x2 >= s.width || y2 >= s.height {
+				continue
+			}
+			j := y2*s.width + x2
+			s.grains[j] += n
+			if s.grains[j] >= sandpileThreshold {
+				s.unstable = append(s.unstable, j)
+			}
+		}
+	}
+}
+
+// Draw colors every cell by its height.
+func (s *SandpileWorld) Draw(dc *gg.Context, p palette) {
+	img, ok := dc.Image().(*image.RGBA)
+	if !ok {
+		return
+	}
+	for y := 0; y < s.height; y++ {
+		for x := 0; x < s.width; x++ {
+			g := s.grains[y*s.width+x]
+			if g == 0 {
+				continue
+			}
+			c := p.color(State(min(g, len(p)-1)))
+			for py := y * s.scale; py < (y+1)*s.scale; py++ {
+				for px := x * s.scale; px < (x+1)*s.scale; px++ {
+					img.Set(px, py, c)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+// scratchBuffers are the world-sized scratch buffers that the counting and
+// weighted updates sum neighbourhoods into. They are only needed for the
+// length of a tick, but allocating them afresh every tick keeps the
+// garbage collector busy for no reason, so the world keeps them. Each has
+// a purpose of its own, and so a size of its own, which only changes with
+// the world's size or the radius of its rule.
+type scratchBuffers struct {
+	// rows, live, window and counts are used by boxCounts.
+	rows, live, window, counts []int32
+	// padded and sums are used by kernelSums.
+	padded, sums []int32
+}
+
+// zeroed returns *buf as n zeroes, reallocating it only if it is too small.
+func zeroed(buf *[]int32, n int) []int32 {
+	if cap(*buf) < n {
+		*buf = make([]int32, n)
+		return *buf
+	}
+	s := (*buf)[:n]
+	for i := range s {
+		s[i] = 0
+	}
+	*buf = s
+	return s
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+// TestSumsDontAllocate checks that once a world has run a tick, summing the
+// neighbourhoods for counting and weighted rules reuses its buffers.
+func TestSumsDontAllocate(t *testing.T) {
+	SetSeed(1)
+	w := NewWorld(64, 48, 1000)
+	weighted, err := ParseWeightedRule("W1,2,1;2,0,2;1,2,1/B5,6/S3..6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		sum  func()
+	}{
+		{"box counts", func() { w.boxCounts(3) }},
+		{"kernel sums", func() { w.kernelSums(weighted.taps, weighted.radius) }},
+	}
+	for _, tt := range tests {
+		tt.sum()
+		if n := testing.AllocsPerRun(10, tt.sum); n != 0 {
+			t.Errorf("%s: %v allocations per tick", tt.name, n)
+		}
+	}
+}
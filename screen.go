@@ -0,0 +1,86 @@
+package main
+
+import (
+	"image"
+
+	"github.com/fogleman/gg"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// handleFullscreen switches between the window and fullscreen with F11 or
+// Alt+Enter. The window gets back the size it had before, even if it was
+// resized in the meantime.
+func (r *Renderer) handleFullscreen() {
+	if !inpututil.IsKeyJustPressed(ebiten.KeyF11) &&
+		!(ebiten.IsKeyPressed(ebiten.KeyAlt) && inpututil.IsKeyJustPressed(ebiten.KeyEnter)) {
+		return
+	}
+	if ebiten.IsFullscreen() {
+		ebiten.SetFullscreen(false)
+		ebiten.SetWindowSize(r.windowSize.X, r.windowSize.Y)
+		return
+	}
+	r.windowSize.X, r.windowSize.Y = ebiten.WindowSize()
+	ebiten.SetFullscreen(true)
+}
+
+// resizeScreen makes size the size of the screen, keeping the point of the
+// world at its centre in place, and fits the simulation to it.
+func (r *Renderer) resizeScreen(size image.Point) {
+	if size == r.screen {
+		return
+	}
+	if r.screen != (image.Point{}) {
+		d := r.screen.Sub(size)
+		r.camera.Pan(float64(d.X)/2, float64(d.Y)/2)
+	}
+	r.screen = size
+	r.fitScreen()
+}
+
+// fitScreen resizes the frame to cover the screen at the cell size, for the
+// simulations that can fill any size: worlds on the square grid, which are
+// resized along with it as World.Resize does, keeping their Anchor in
+// place, and simulations without bounds, which only need a larger view.
+// Other simulations keep their size, and the camera shows them as they
+// are. The change is queued like an edit, since it replaces the frame the
+// world is drawn into.
+func (r *Renderer) fitScreen() {
+	cells := r.screen.Div(r.cellSize)
+	if cells.X < 1 || cells.Y < 1 || !r.fitsScreen() {
+		return
+	}
+	r.queueEdit(func() {
+		if w, ok := r.sim.(*World); ok {
+			w.Resize(cells.X, cells.Y)
+		}
+		r.dc = gg.NewContext(cells.X, cells.Y)
+		r.frame, r.background = nil, nil
+	})
+}
+
+// fitsScreen reports whether fitScreen resizes the simulation to the
+// screen.
+func (r *Renderer) fitsScreen() bool {
+	if w, ok := r.sim.(*World); ok {
+		return w.Grid() == SquareGrid
+	}
+	_, view := r.sim.(viewSimulation)
+	return view
+}
+
+// setDeviceScale records the device scale factor s. The frame of
+// simulations that fit the screen is allocated at its physical resolution
+// by fitScreen, a cell per physical pixel at cell size 1; the others keep
+// their size in logical pixels, so the camera zooms in by as much as the
+// scale grows, around the centre of the screen.
+func (r *Renderer) setDeviceScale(s float64) {
+	if s == r.deviceScale || s <= 0 {
+		return
+	}
+	if !r.fitsScreen() {
+		r.camera.ZoomAt(float64(r.screen.X)/2, float64(r.screen.Y)/2, s/r.deviceScale)
+	}
+	r.deviceScale = s
+}
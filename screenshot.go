@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// toastDuration is how long a toast stays on the screen.
+const toastDuration = 2 * time.Second
+
+// toast is a short message shown at the top of the screen for a while, to
+// confirm something happened off screen, such as a file being written.
+type toast struct {
+	text string
+	at   time.Time
+}
+
+// showToast shows text as a toast, replacing the one shown before.
+func (r *Renderer) showToast(format string, args ...interface{}) {
+	r.toast = toast{text: fmt.Sprintf(format, args...), at: time.Now()}
+}
+
+// drawToast draws the current toast, if it hasn't expired, centred at the
+// top of the screen.
+func (r *Renderer) drawToast(screen *ebiten.Image) {
+	t := r.toast
+	if t.text == "" || time.Since(t.at) > toastDuration {
+		return
+	}
+	// The debug font is 6×16 pixels.
+	w, h := 6*len(t.text)+2*minimapMargin, 16+minimapMargin
+	x, y := (r.screen.X-w)/2, minimapMargin
+	ebitenutil.DrawRect(screen, float64(x), float64(y), float64(w), float64(h), minimapBackground)
+	ebitenutil.DebugPrintAt(screen, t.text, x+minimapMargin, y+minimapMargin/2)
+}
+
+// screenshotKind is what a pending screenshot captures.
+type screenshotKind int
+
+const (
+	noScreenshot screenshotKind = iota
+	// screenshotScreen captures the screen as drawn, without the HUD and
+	// other overlays.
+	screenshotScreen
+	// screenshotWorld captures the world at one pixel per cell.
+	screenshotWorld
+	// screenshotSVG exports the current generation of a world as an SVG
+	// drawing.
+	screenshotSVG
+)
+
+// handleScreenshot takes a screenshot of the screen with the S key, of the
+// whole world at one pixel per cell with Shift+S, or exports the world as
+// an SVG drawing with Ctrl+S. It is taken by the next Draw.
+func (r *Renderer) handleScreenshot() {
+	if !inpututil.IsKeyJustPressed(ebiten.KeyS) {
+		return
+	}
+	switch {
+	case ebiten.IsKeyPressed(ebiten.KeyControl):
+		r.screenshot = screenshotSVG
+	case ebiten.IsKeyPressed(ebiten.KeyShift):
+		r.screenshot = screenshotWorld
+	default:
+		r.screenshot = screenshotScreen
+	}
+}
+
+// takeScreenshot writes the pending screenshot, if any, to a timestamped
+// PNG or SVG file in r.screenshotDir and confirms it with a toast. Draw calls it
+// once the world is on screen, before any overlays.
+func (r *Renderer) takeScreenshot(screen *ebiten.Image, p palette) {
+	kind := r.screenshot
+	if kind == noScreenshot {
+		return
+	}
+	r.screenshot = noScreenshot
+	if kind == screenshotSVG {
+		name := r.captureName(".svg")
+		if err := r.saveSVG(name, p); err != nil {
+			r.showToast("SVG export failed: %v", err)
+			return
+		}
+		r.showToast("saved %s", name)
+		return
+	}
+
+	var img image.Image
+	switch kind {
+	case screenshotScreen:
+		img = captureScreen(screen, nil)
+	case screenshotWorld:
+		img = r.worldPixels(p)
+	}
+	name := r.captureName(".png")
+	if err := writePNG(name, img); err != nil {
+		r.showToast("screenshot failed: %v", err)
+		return
+	}
+	r.showToast("saved %s", name)
+}
+
+// captureName returns the name of a new capture file with the extension
+// ext in r.screenshotDir, after the current time.
+func (r *Renderer) captureName(ext string) string {
+	return filepath.Join(r.screenshotDir, time.Now().Format("life-20060102-150405.000")+ext)
+}
+
+// captureScreen copies the pixels of screen into dst, or a new image if
+// dst is nil or of the wrong size, and returns it. The window shows the
+// parts of the screen the theme leaves transparent as black, and so does
+// the copy: every pixel is made opaque, which composes the premultiplied
+// colors of the screen over black.
+//
+// Reading back the screen is slow, and only possible during Draw.
+func captureScreen(screen *ebiten.Image, dst *image.RGBA) *image.RGBA {
+	b := screen.Bounds()
+	if dst == nil || dst.Rect != b {
+		dst = image.NewRGBA(b)
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		pix := dst.Pix[dst.PixOffset(b.Min.X, y):]
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := screen.At(x, y).(color.RGBA)
+			i := 4 * (x - b.Min.X)
+			pix[i], pix[i+1], pix[i+2], pix[i+3] = c.R, c.G, c.B, 0xff
+		}
+	}
+	return dst
+}
+
+// worldPixels returns the cells of the simulation at one pixel per cell, on
+// the Dead color of p. Simulations that can't write their pixels directly
+// give the frame as last drawn instead.
+func (r *Renderer) worldPixels(p palette) *image.RGBA {
+	frame := r.dc.Image().(*image.RGBA)
+	img := image.NewRGBA(frame.Rect)
+	if ps, ok := r.sim.(pixelSimulation); ok {
+		draw.Draw(img, img.Rect, image.NewUniform(p[Dead]), image.Point{}, draw.Src)
+		if ps.DrawPixels(img, p) {
+			return img
+		}
+	}
+	copy(img.Pix, frame.Pix)
+	return img
+}
+
+// writePNG encodes img as a PNG file called name.
+func writePNG(name string, img image.Image) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// scriptStates is the number of states a scripted rule can use.
+const scriptStates = 8
+
+// scriptTimeout is how long a script may run for when it is loaded and then
+// on every call of its transition function, before it is stopped and
+// treated as having failed. It keeps a script that loops forever from
+// hanging the world update loop, and with it the window.
+const scriptTimeout = 250 * time.Millisecond
+
+// scriptKey identifies the inputs of one evaluation of a scripted rule: the
+// cell's state and how many of its neighbours are in each state.
+type scriptKey struct {
+	self   State
+	counts [scriptStates]uint8
+}
+
+// ScriptRule is a rule whose transition function is written in Lua. The
+// script must define a global function
+//
+//	function transition(state, n, counts)
+//
+// which is called with the cell's state, the number of live neighbours and
+// a table counting the neighbours in each state, and returns the next
+// state, either as a number or as a boolean meaning Alive or Dead. The
+// script may set a global states to the number of states it uses, at most
+// 8, and can read the global tick, the number of the current tick.
+//
+// Scripts run in a sandbox without the io, os and package libraries or any
+// way to load further code, and are stopped if they run for longer than
+// scriptTimeout. Since a neighbourhood only has so many distinct
+// configurations, results are cached for the duration of a tick, so the
+// script is called once per configuration rather than once per cell.
+type ScriptRule struct {
+	name   string
+	vm     *lua.LState
+	fn     lua.LValue
+	states int
+	tick   int
+	cache  map[scriptKey]State
+	// failed is set once the script has raised an error, which is only
+	// logged the first time.
+	failed bool
+}
+
+// LoadScriptRule loads the rule script in the named file.
+func LoadScriptRule(filename string) (*ScriptRule, error) {
+	L := newSandbox()
+	ctx, cancel := context.WithTimeout(context.Background(), scriptTimeout)
+	L.SetContext(ctx)
+	err := L.DoFile(filename)
+	cancel()
+	L.RemoveContext()
+	if err != nil {
+		err = scriptError(ctx, err)
+		L.Close()
+		return nil, fmt.Errorf("rule script %s: %v", filename, err)
+	}
+	fn := L.GetGlobal("transition")
+	if fn.Type() != lua.LTFunction {
+		L.Close()
+		return nil, fmt.Errorf("rule script %s: no transition function defined", filename)
+	}
+	states := 2
+	if n, ok := L.GetGlobal("states").(lua.LNumber); ok {
+		states = int(n)
+	}
+	if states < 2 || states > scriptStates {
+		L.Close()
+		return nil, fmt.Errorf("rule script %s: states must be between 2 and %d", filename, scriptStates)
+	}
+	return &ScriptRule{
+		name:   filename,
+		vm:     L,
+		fn:     fn,
+		states: states,
+		cache:  make(map[scriptKey]State),
+	}, nil
+}
+
+// newSandbox returns a Lua state with only the base, table, string and
+// math libraries, minus the base functions that load code from files or
+// strings.
+func newSandbox() *lua.LState {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, name := range []string{"dofile", "loadfile", "load", "loadstring", "require", "module"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+	return L
+}
+
+// States returns the number of cell states of the rule.
+func (r *ScriptRule) States() int {
+	return r.states
+}
+
+// tickingRule is implemented by rules that need to know when a new tick
+// starts.
+type tickingRule interface {
+	beginTick()
+}
+
+// beginTick implements tickingRule. It drops the results cached during the
+// previous tick, so scripts depending on tick see it advance.
+func (r *ScriptRule) beginTick() {
+	r.tick++
+	r.vm.SetGlobal("tick", lua.LNumber(r.tick))
+	for k := range r.cache {
+		delete(r.cache, k)
+	}
+}
+
+// Next implements Rule.
+func (r *ScriptRule) Next(self State, neighbours []State) State {
+	key := scriptKey{self: clampScriptState(self)}
+	for _, s := range neighbours {
+		key.counts[clampScriptState(s)]++
+	}
+	if next, ok := r.cache[key]; ok {
+		return next
+	}
+	next := r.call(key)
+	r.cache[key] = next
+	return next
+}
+
+// clampScriptState maps states a script can't have produced, like those
+// painted by turmites, onto its highest state.
+func clampScriptState(s State) State {
+	if s >= scriptStates {
+		return scriptStates - 1
+	}
+	return s
+}
+
+// call runs the script's transition function for key.
+func (r *ScriptRule) call(key scriptKey) State {
+	if r.failed {
+		return Dead
+	}
+	counts := r.vm.CreateTable(scriptStates, 0)
+	for s, n := range key.counts {
+		counts.RawSetInt(s, lua.LNumber(n))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), scriptTimeout)
+	r.vm.SetContext(ctx)
+	err := r.vm.CallByParam(lua.P{Fn: r.fn, NRet: 1, Protect: true},
+		lua.LNumber(key.self), lua.LNumber(key.counts[Alive]), counts)
+	cancel()
+	r.vm.RemoveContext()
+	if err != nil {
+		err = scriptError(ctx, err)
+		log.Printf("rule script %s: %v", r.name, err)
+		r.failed = true
+		return Dead
+	}
+	ret := r.vm.Get(-1)
+	r.vm.Pop(1)
+	switch ret := ret.(type) {
+	case lua.LBool:
+		if ret {
+			return Alive
+		}
+	case lua.LNumber:
+		if 0 <= ret && int(ret) < r.states {
+			return State(ret)
+		}
+	}
+	return Dead
+}
+
+// scriptError returns err, raised by a script run with ctx, or a plainer
+// error if it was stopped for running out of time.
+func scriptError(ctx context.Context, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("stopped after running for more than %v", scriptTimeout)
+	}
+	return err
+}
+
+// String returns the name of the script file.
+func (r *ScriptRule) String() string {
+	return r.name
+}
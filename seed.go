@@ -0,0 +1,21 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// seeds hands out the seeds of the random number generators of new worlds,
+// so that seeding it once with SetSeed makes a whole run reproducible.
+var seeds = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+// SetSeed seeds the random number generators of the worlds created from
+// now on.
+func SetSeed(seed int64) {
+	seeds.Seed(seed)
+}
+
+// newRand returns the random number generator of a new world.
+func newRand() *rand.Rand {
+	return rand.New(rand.NewSource(seeds.Int63()))
+}
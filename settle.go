@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// SettleAction is what the world update loop does once a world has died
+// out or settled into a still life or period 2 oscillation.
+type SettleAction int
+
+const (
+	// SettleContinue keeps running the world.
+	SettleContinue SettleAction = iota
+	// SettlePause pauses the simulation.
+	SettlePause
+	// SettleReseed replaces the world with a fresh random soup.
+	SettleReseed
+	// SettleExit quits after printing a summary.
+	SettleExit
+)
+
+var settleActionNames = map[SettleAction]string{
+	SettleContinue: "continue",
+	SettlePause:    "pause",
+	SettleReseed:   "reseed",
+	SettleExit:     "exit",
+}
+
+func (a SettleAction) String() string {
+	if name, ok := settleActionNames[a]; ok {
+		return name
+	}
+	return fmt.Sprintf("SettleAction(%d)", int(a))
+}
+
+// ParseSettleAction returns the settle action with the given name, as
+// printed by SettleAction.String.
+func ParseSettleAction(s string) (SettleAction, error) {
+	for a, name := range settleActionNames {
+		if name == s {
+			return a, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown settle action %q", s)
+}
+
+// Settled describes how the world has come to rest, or returns "" if it
+// hasn't: either every cell is dead, or the world is a still life or a
+// period 2 oscillation.
+func (w *World) Settled() string {
+	switch {
+	case w.Population() == 0:
+		return "died out"
+	case w.Period() == 1:
+		return fmt.Sprintf("settled into a still life of %d cells", w.Population())
+	case w.Period() == 2:
+		return fmt.Sprintf("settled into a period 2 oscillation of %d cells", w.Population())
+	}
+	return ""
+}
+
+// Reseed replaces the world's cells with a random soup as dense as the one
+// it was created with.
+func (w *World) Reseed() {
+	w.Reset()
+	w.init(w.seedCells)
+	w.seedStates()
+}
+
+// seedStates spreads the live cells of a fresh soup over the states the
+// rule starts from, where that isn't simply Alive.
+func (w *World) seedStates() {
+	switch rule := w.rule.(type) {
+	case *CyclicRule:
+		w.fillRandom(rule.States())
+	case multiColorRule:
+		w.colorize(rule.Colors())
+	}
+}
+
+// afterUpdate is called by the world update loop after every tick, to act
+// on what happened in it.
+func (r *Renderer) afterUpdate() {
+	w, ok := r.sim.(*World)
+	if !ok {
+		return
+	}
+	r.graph.record(w.Stats())
+	r.history.record(w)
+	if p := w.Period(); r.pauseOnCycle && p > 0 && p != r.lastPeriod {
+		r.paused.Store(true)
+	}
+	r.lastPeriod = w.Period()
+
+	how := w.Settled()
+	if how == "" {
+		return
+	}
+	switch r.onSettle {
+	case SettlePause:
+		if !r.settled {
+			r.paused.Store(true)
+		}
+	case SettleReseed:
+		w.Reseed()
+		how = ""
+	case SettleExit:
+		log.Printf("world %s: %s", how, w.Summary())
+		r.Shutdown()
+	}
+	r.settled = how != ""
+}
@@ -0,0 +1,82 @@
+package main
+
+// Snapshot is a copy of the state of a World at one point in time, for
+// undo, rewinding and save slots. The world's settings, like its boundary
+// mode, and its random number generator aren't part of it.
+type Snapshot struct {
+	area          []State
+	width, height int
+	rule          Rule
+	phase         int
+	turmites      []Turmite
+	ages          []uint16
+	flags         []CellFlags
+	zones         []uint8
+	zoneRules     []Rule
+	sinceMutation int
+	population    int
+	cycles        cycleDetector
+	period        int
+	generation    int
+}
+
+// Snapshot copies the current state of the world.
+func (w *World) Snapshot() *Snapshot {
+	s := &Snapshot{
+		area:          append([]State(nil), w.area...),
+		width:         w.width,
+		height:        w.height,
+		rule:          w.rule,
+		phase:         w.phase,
+		ages:          append([]uint16(nil), w.ages...),
+		flags:         append([]CellFlags(nil), w.flags...),
+		zones:         append([]uint8(nil), w.zones...),
+		zoneRules:     append([]Rule(nil), w.zoneRules...),
+		sinceMutation: w.sinceMutation,
+		population:    w.population,
+		cycles:        w.cycles.clone(),
+		period:        w.period,
+		generation:    w.generation,
+	}
+	for _, t := range w.turmites {
+		s.turmites = append(s.turmites, *t)
+	}
+	return s
+}
+
+// Restore puts the world back into the state s was taken in. The snapshot
+// is left untouched, so it can be restored again.
+func (w *World) Restore(s *Snapshot) {
+	w.area = append(w.area[:0], s.area...)
+	w.width, w.height = s.width, s.height
+	w.rule = s.rule
+	w.phase = s.phase
+	w.turmites = w.turmites[:0]
+	for _, t := range s.turmites {
+		t := t
+		w.turmites = append(w.turmites, &t)
+	}
+	w.ages = append([]uint16(nil), s.ages...)
+	w.flags = append([]CellFlags(nil), s.flags...)
+	w.zones = append([]uint8(nil), s.zones...)
+	w.zoneRules = append([]Rule(nil), s.zoneRules...)
+	w.sinceMutation = s.sinceMutation
+	w.population = s.population
+	w.cycles = s.cycles.clone()
+	w.period = s.period
+	w.generation = s.generation
+	w.chunks.invalidate()
+	w.updateBounds()
+}
+
+// clone returns a copy of d that doesn't share its map.
+func (d *cycleDetector) clone() cycleDetector {
+	c := *d
+	if d.seen != nil {
+		c.seen = make(map[uint64]int, len(d.seen))
+		for h, g := range d.seen {
+			c.seen[h] = g
+		}
+	}
+	return c
+}
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+// TestRestoreKeepsEvolving checks that a world keeps evolving after a
+// snapshot is restored or it is reset and redrawn, even when its cells are
+// put back exactly as they were in the input of the previous generation.
+func TestRestoreKeepsEvolving(t *testing.T) {
+	blinker := func(w *World) {
+		for x := 19; x <= 21; x++ {
+			w.Set(x, 20, true)
+		}
+	}
+	tests := []struct {
+		name string
+		back func(w *World, s *Snapshot)
+	}{
+		{"restore", func(w *World, s *Snapshot) { w.Restore(s) }},
+		{"reset", func(w *World, s *Snapshot) { w.Reset(); blinker(w) }},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorld(40, 40, 0)
+			blinker(w)
+			s := w.Snapshot()
+			w.Step(1)
+			tt.back(w, s)
+			for gen := 1; gen <= 4; gen++ {
+				w.Step(1)
+				vertical := gen%2 == 1
+				if w.Alive(20, 19) != vertical || w.Alive(19, 20) == vertical {
+					t.Fatalf("generation %d after going back: blinker is in the wrong phase", gen)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"image"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+// SparseWorld is an unbounded two-state world for Life-like rules on the
+// Moore neighbourhood. Only the coordinates of live cells are stored, so
+// the universe is effectively infinite and patterns can travel forever.
+type SparseWorld struct {
+	live map[image.Point]struct{}
+	rule *LifeRule
+	// counts is scratch space for the neighbour counts of a tick, and
+	// spare the map of the previous generation, reused for the next one.
+	counts map[image.Point]int
+	spare  map[image.Point]struct{}
+}
+
+// NewSparseWorld creates an unbounded world running rule, seeded with up to
+// maxInitLiveCells random live cells within the rectangle from the origin
+// to (width, height).
+func NewSparseWorld(width, height, maxInitLiveCells int, rule *LifeRule) *SparseWorld {
+	s := &SparseWorld{
+		live:   make(map[image.Point]struct{}),
+		rule:   rule,
+		counts: make(map[image.Point]int),
+	}
+	rng := newRand()
+	for i := 0; i < maxInitLiveCells; i++ {
+		s.live[image.Pt(rng.Intn(width), rng.Intn(height))] = struct{}{}
+	}
+	return s
+}
+
+// Population returns the number of live cells.
+func (s *SparseWorld) Population() int {
+	return len(s.live)
+}
+
+// Alive reports whether the cell at (x, y) is alive.
+func (s *SparseWorld) Alive(x, y int) bool {
+	_, ok := s.live[image.Pt(x, y)]
+	return ok
+}
+
+// Set makes the cell at (x, y) alive or dead.
+func (s *SparseWorld) Set(x, y int, alive bool) {
+	if alive {
+		s.live[image.Pt(x, y)] = struct{}{}
+	} else {
+		delete(s.live, image.Pt(x, y))
+	}
+}
+
+// Update applies the rule to every live cell and every cell next to one;
+// all other cells have no live neighbours and stay dead, except under rules
+// with B0, whose infinite flashing background can't be represented.
+func (s *SparseWorld) Update(t *time.Time) {
+	for p := range s.counts {
+		delete(s.counts, p)
+	}
+	for p := range s.live {
+		if _, ok := s.counts[p]; !ok {
+			s.counts[p] = 0
+		}
+		for _, o := range mooreOffsets {
+			s.counts[image.Pt(p.X+o[0], p.Y+o[1])]++
+		}
+	}
+	next := s.spare
+	if next == nil {
+		next = make(map[image.Point]struct{}, len(s.live))
+	}
+	for p := range next {
+		delete(next, p)
+	}
+	for p, n := range s.counts {
+		_, alive := s.live[p]
+		if s.rule.nextAlive(alive, n) {
+			next[p] = struct{}{}
+		}
+	}
+	s.live, s.spare = next, s.live
+}
+
+// Draw paints the live cells in the screen-sized window at the origin.
+func (s *SparseWorld) Draw(dc *gg.Context, p palette) {
+	s.DrawView(dc, p, image.Rect(0, 0, dc.Width(), dc.Height()))
+}
+
+// DrawView implements viewSimulation by painting the live cells inside
+// view, whose top-left corner is drawn at the top-left of the screen.
+func (s *SparseWorld) DrawView(dc *gg.Context, p palette, view image.Rectangle) {
+	dc.SetColor(p.color(Alive))
+	for c := range s.live {
+		if c.In(view) {
+			dc.SetPixel(c.X-view.Min.X, c.Y-view.Min.Y)
+		}
+	}
+}
+
+// viewSimulation is implemented by simulations without fixed bounds, which
+// the renderer shows through a camera.
+type viewSimulation interface {
+	Simulation
+	DrawView(dc *gg.Context, p palette, view image.Rectangle)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CollisionPolicy decides which species gets a dead cell that several
+// species would give birth into at once.
+type CollisionPolicy int
+
+const (
+	// CollisionMajority gives the cell to the claiming species with the most
+	// live neighbours; ties go to the species listed first.
+	CollisionMajority CollisionPolicy = iota
+	// CollisionPriority gives the cell to the claiming species listed first.
+	CollisionPriority
+	// CollisionAnnihilation leaves the cell dead when more than one species
+	// claims it.
+	CollisionAnnihilation
+)
+
+var collisionNames = map[CollisionPolicy]string{
+	CollisionMajority:     "majority",
+	CollisionPriority:     "priority",
+	CollisionAnnihilation: "annihilation",
+}
+
+func (p CollisionPolicy) String() string {
+	if name, ok := collisionNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("CollisionPolicy(%d)", int(p))
+}
+
+// ParseCollisionPolicy returns the collision policy with the given name, as
+// printed by CollisionPolicy.String.
+func ParseCollisionPolicy(s string) (CollisionPolicy, error) {
+	for p, name := range collisionNames {
+		if name == s {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown collision policy %q", s)
+}
+
+// SpeciesRule runs several species on one grid, each under its own
+// Life-like rule. Species i occupies the state Alive+i. Every species only
+// sees its own members: a cell of species i survives if rule i lets it
+// survive among its own kind, and a dead cell is claimed by every species
+// whose rule would give birth there. Claims by more than one species are
+// settled by the collision policy.
+type SpeciesRule struct {
+	species []*LifeRule
+	policy  CollisionPolicy
+}
+
+// NewSpeciesRule returns a rule running the given species, at most eight,
+// under the given collision policy.
+func NewSpeciesRule(species []*LifeRule, policy CollisionPolicy) (*SpeciesRule, error) {
+	if len(species) == 0 || len(species) > 8 {
+		return nil, fmt.Errorf("species rule: %d species, want between 1 and 8", len(species))
+	}
+	return &SpeciesRule{species: species, policy: policy}, nil
+}
+
+// ParseSpeciesRule parses a comma separated list of Life-like rulestrings,
+// one per species, e.g. "B3/S23,B36/S23".
+func ParseSpeciesRule(s string, policy CollisionPolicy) (*SpeciesRule, error) {
+	var species []*LifeRule
+	for _, rs := range strings.Split(s, ",") {
+		r, err := ParseRule(rs)
+		if err != nil {
+			return nil, err
+		}
+		species = append(species, r)
+	}
+	return NewSpeciesRule(species, policy)
+}
+
+// Colors returns the number of species.
+func (r *SpeciesRule) Colors() int {
+	return len(r.species)
+}
+
+// States returns the number of cell states of the rule, Dead included.
+func (r *SpeciesRule) States() int {
+	return len(r.species) + 1
+}
+
+// Next implements Rule.
+func (r *SpeciesRule) Next(self State, neighbours []State) State {
+	var counts [8]int
+	for _, s := range neighbours {
+		if s != Dead && int(s-Alive) < len(r.species) {
+			counts[s-Alive]++
+		}
+	}
+	if self != Dead {
+		i := int(self - Alive)
+		if i < len(r.species) && counts[i] < len(r.species[i].survival) && r.species[i].survival[counts[i]] {
+			return self
+		}
+		return Dead
+	}
+	winner, claims := -1, 0
+	for i, sp := range r.species {
+		if counts[i] >= len(sp.birth) || !sp.birth[counts[i]] {
+			continue
+		}
+		claims++
+		if winner < 0 || r.policy == CollisionMajority && counts[i] > counts[winner] {
+			winner = i
+		}
+	}
+	if winner < 0 || claims > 1 && r.policy == CollisionAnnihilation {
+		return Dead
+	}
+	return Alive + State(winner)
+}
+
+// String returns the species' rules separated by commas.
+func (r *SpeciesRule) String() string {
+	names := make([]string, len(r.species))
+	for i, sp := range r.species {
+		names[i] = sp.String()
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	// Register the PNG decoder for sprites loaded from files.
+	_ "image/png"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/fogleman/gg"
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	// spriteSize is the size in pixels the built-in sprites are drawn at,
+	// large enough to stay smooth at the highest zoom they are likely to
+	// be seen at.
+	spriteSize = 32
+	// spriteMinZoom is the size in pixels cells must be drawn at on the
+	// screen for sprites to be used; smaller cells are plain pixels
+	// anyway.
+	spriteMinZoom = 3
+)
+
+// spriteShapes draw the built-in sprites in white on a transparent
+// spriteSize×spriteSize context, to be tinted with the color of each cell.
+var spriteShapes = map[string]func(dc *gg.Context){
+	"rounded": func(dc *gg.Context) {
+		dc.DrawRoundedRectangle(1, 1, spriteSize-2, spriteSize-2, spriteSize/4)
+		dc.Fill()
+	},
+	"circle": func(dc *gg.Context) {
+		dc.DrawCircle(spriteSize/2, spriteSize/2, spriteSize/2-1)
+		dc.Fill()
+	},
+	"diamond": func(dc *gg.Context) {
+		dc.DrawRegularPolygon(4, spriteSize/2, spriteSize/2, spriteSize/2, 0)
+		dc.Fill()
+	},
+}
+
+// spriteNames returns the names of the built-in sprites in sorted order.
+func spriteNames() []string {
+	names := make([]string, 0, len(spriteShapes))
+	for name := range spriteShapes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// loadSprite returns the built-in sprite with the given name, or the PNG
+// image at the path name if it ends in .png. Sprites are tinted with the
+// color of the cell they are drawn for, so should be white where the cell
+// is to show its color.
+func loadSprite(name string) (image.Image, error) {
+	if strings.HasSuffix(strings.ToLower(name), ".png") {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		img, _, err := image.Decode(f)
+		if err != nil {
+			return nil, fmt.Errorf("sprite %s: %v", name, err)
+		}
+		return img, nil
+	}
+	shape, ok := spriteShapes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown sprite %q, want a .png file or one of %s", name, strings.Join(spriteNames(), ", "))
+	}
+	dc := gg.NewContext(spriteSize, spriteSize)
+	dc.SetColor(color.White)
+	shape(dc)
+	return dc.Image(), nil
+}
+
+// spritesOn reports whether the cells are drawn as sprites rather than
+// into the frame: there is a sprite, the world is on the square grid, the
+// cells are large enough on the screen, and no view colors them by more
+// than their state.
+func (r *Renderer) spritesOn() bool {
+	w, ok := r.sim.(*World)
+	return ok && r.spriteSrc != nil && w.Grid() == SquareGrid &&
+		r.camera.Zoom >= spriteMinZoom && !r.neighbourView && !r.heatmap && !r.ageColors
+}
+
+// drawSprites draws a sprite for every cell of w on the screen that isn't
+// dead, tinted with its color from p. All the calls draw the same image
+// with a scale-only color matrix, so Ebiten batches them into a few draw
+// commands.
+func (r *Renderer) drawSprites(screen *ebiten.Image, w *World, p palette) {
+	if r.sprite == nil {
+		r.sprite = ebiten.NewImageFromImage(r.spriteSrc)
+	}
+	sw, sh := r.sprite.Size()
+	x0, y0 := r.camera.ScreenToWorld(0, 0)
+	x1, y1 := r.camera.ScreenToWorld(float64(r.screen.X), float64(r.screen.Y))
+	visible := image.Rect(int(math.Floor(x0)), int(math.Floor(y0)), int(math.Ceil(x1)), int(math.Ceil(y1)))
+
+	// tints are the color matrix scales of the states, in straight alpha.
+	var tints [256][4]float64
+	for s := range tints {
+		c := color.NRGBAModel.Convert(p.color(State(s))).(color.NRGBA)
+		tints[s] = [4]float64{float64(c.R) / 0xff, float64(c.G) / 0xff, float64(c.B) / 0xff, float64(c.A) / 0xff}
+	}
+	op := &ebiten.DrawImageOptions{Filter: ebiten.FilterLinear}
+	w.ForEachIn(visible.Intersect(w.bounds), func(x, y int, s State) {
+		if s == Dead {
+			return
+		}
+		op.GeoM.Reset()
+		op.GeoM.Scale(1/float64(sw), 1/float64(sh))
+		op.GeoM.Translate(float64(x), float64(y))
+		op.GeoM.Concat(r.camera.GeoM())
+		op.ColorM.Reset()
+		t := &tints[s]
+		op.ColorM.Scale(t[0], t[1], t[2], t[3])
+		screen.DrawImage(r.sprite, op)
+	})
+}
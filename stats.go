@@ -0,0 +1,32 @@
+package main
+
+// Stats are the vital statistics of a world after an Update.
+type Stats struct {
+	Generation int
+	// Population is the number of cells that aren't dead.
+	Population int
+	// Births and Deaths are the number of cells that came to life and died
+	// in the last Update.
+	Births, Deaths int
+	// Density is the fraction of the cells that aren't dead.
+	Density float64
+
+	// Entropy and Clustering are only computed when World.Metrics is set.
+	// Entropy is the Shannon entropy in bits of the patterns of the 3×3
+	// blocks around every cell, and Clustering the average clustering
+	// coefficient of the graph of neighbouring live cells.
+	Entropy, Clustering float64
+}
+
+// Stats returns the statistics of the last Update.
+func (w *World) Stats() Stats {
+	return Stats{
+		Generation: w.generation,
+		Population: w.population,
+		Births:     w.births,
+		Deaths:     w.deaths,
+		Density:    float64(w.population) / float64(len(w.area)),
+		Entropy:    w.entropy,
+		Clustering: w.clustering,
+	}
+}
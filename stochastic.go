@@ -0,0 +1,83 @@
+package main
+
+import "math/rand"
+
+// StochasticRule wraps a rule and flips its outcome with a fixed
+// probability, the temperature: a cell that would be dead comes alive and
+// any other cell dies. At temperature 0 it behaves exactly like the wrapped
+// rule; raising it lets noise-driven phase transitions be studied.
+type StochasticRule struct {
+	Rule
+	Temperature float64
+	rng         *rand.Rand
+}
+
+// NewStochasticRule wraps rule with the given temperature, drawing random
+// numbers from rng so that runs with the same seed are reproducible.
+func NewStochasticRule(rule Rule, temperature float64, rng *rand.Rand) *StochasticRule {
+	return &StochasticRule{Rule: rule, Temperature: temperature, rng: rng}
+}
+
+// Next implements Rule.
+func (r *StochasticRule) Next(self State, neighbours []State) State {
+	next := r.Rule.Next(self, neighbours)
+	if r.Temperature > 0 && r.rng.Float64() < r.Temperature {
+		if next == Dead {
+			return Alive
+		}
+		return Dead
+	}
+	return next
+}
+
+// applyTemperature flips the outcome of the wrapped rule in the new
+// generation for each cell with probability r.Temperature, then recounts
+// its births and deaths against its input, w.prev, so that a cell the rule
+// changed and the temperature changed back counts as neither.
+func (w *World) applyTemperature(r *StochasticRule) {
+	if r.Temperature <= 0 {
+		return
+	}
+	for i, s := range w.area {
+		if r.rng.Float64() >= r.Temperature {
+			continue
+		}
+		if s == Dead {
+			w.area[i] = Alive
+			w.growBounds(i)
+		} else {
+			w.area[i] = Dead
+		}
+	}
+	w.population -= w.births - w.deaths
+	w.births, w.deaths = 0, 0
+	for i, s := range w.area {
+		w.countChange(w.prev[i], s)
+	}
+	w.changes = w.changes[:0]
+	w.recordChanges(w.prev, w.area)
+	w.chunks.invalidate()
+}
+
+// addNoise flips a Noise fraction of the cells, picked at random: dead
+// cells come alive and any other cell dies. A fractional number of cells is
+// rounded up or down at random, so that on average exactly the requested
+// fraction is flipped even in small worlds.
+func (w *World) addNoise() {
+	if w.Noise <= 0 {
+		return
+	}
+	f := w.Noise * float64(len(w.area))
+	n := int(f)
+	if w.rng.Float64() < f-float64(n) {
+		n++
+	}
+	for ; n > 0; n-- {
+		i := w.rng.Intn(len(w.area))
+		if w.area[i] == Dead {
+			w.set(i, Alive)
+		} else {
+			w.set(i, Dead)
+		}
+	}
+}
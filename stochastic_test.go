@@ -0,0 +1,71 @@
+package main
+
+import "testing"
+
+// TestStochasticRuleWrapsUpdate checks that a stochastic rule at
+// temperature 0 evolves exactly like the rule it wraps, for rules that
+// aren't applied cell by cell: a block rule and radius rules.
+func TestStochasticRuleWrapsUpdate(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   Rule
+		radius int
+	}{
+		{"critters", Critters, 0},
+		{"bbm", BBM, 0},
+		{"ltl", Bugs, 0},
+		{"life radius 2", MustParseRule("B3/S23"), 2},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			SetSeed(1)
+			want := NewWorld(48, 32, 600)
+			SetSeed(1)
+			got := NewWorld(48, 32, 600)
+			want.SetRule(tt.rule)
+			got.SetRule(NewStochasticRule(tt.rule, 0, got.Rand()))
+			want.Radius, got.Radius = tt.radius, tt.radius
+			start := append([]State(nil), want.area...)
+			changed := false
+			for gen := 1; gen <= 20; gen++ {
+				want.Step(1)
+				got.Step(1)
+				for i := range want.area {
+					changed = changed || want.area[i] != start[i]
+					if got.area[i] != want.area[i] {
+						t.Fatalf("generation %d: cell %d is %v, want %v", gen, i, got.area[i], want.area[i])
+					}
+				}
+				if got.Population() != want.Population() {
+					t.Fatalf("generation %d: population %d, want %d", gen, got.Population(), want.Population())
+				}
+			}
+			if !changed {
+				t.Fatal("the wrapped rule never changed the world")
+			}
+		})
+	}
+}
+
+// TestStochasticRuleCounts checks that the births, deaths and population
+// of a stochastic block rule add up when the temperature flips cells.
+func TestStochasticRuleCounts(t *testing.T) {
+	SetSeed(1)
+	w := NewWorld(32, 32, 300)
+	w.SetRule(NewStochasticRule(Critters, 0.05, w.Rand()))
+	for gen := 1; gen <= 20; gen++ {
+		before := w.Population()
+		w.Step(1)
+		pop := 0
+		for _, s := range w.area {
+			pop += live(s)
+		}
+		if w.Population() != pop {
+			t.Fatalf("generation %d: population %d, counted %d", gen, w.Population(), pop)
+		}
+		if before+w.births-w.deaths != pop {
+			t.Fatalf("generation %d: %d + %d births - %d deaths != %d", gen, before, w.births, w.deaths, pop)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// streamQuality is the JPEG quality of the frames of the MJPEG stream.
+const streamQuality = 85
+
+// streamPage is the page served at the root of the stream server, which
+// just shows the stream.
+const streamPage = `<!DOCTYPE html>
+<title>` + windowTitle + `</title>
+<style>body { margin: 0; background: #000; } img { display: block; margin: auto; max-width: 100vw; max-height: 100vh; image-rendering: pixelated; }</style>
+<img src="/stream">
+`
+
+// frameStream hands the latest encoded frame to every client watching the
+// stream. Clients that are slower than the simulation skip frames rather
+// than hold it up.
+type frameStream struct {
+	mu      sync.Mutex
+	clients map[chan []byte]struct{}
+	last    []byte
+}
+
+// publish sends the JPEG frame to every client, replacing any frame a
+// client hasn't taken yet.
+func (s *frameStream) publish(frame []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last = frame
+	for ch := range s.clients {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- frame
+	}
+}
+
+// subscribe returns a channel that receives the frames published from now
+// on, starting with the last one, and a function that cancels it.
+func (s *frameStream) subscribe() (<-chan []byte, func()) {
+	ch := make(chan []byte, 1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.clients == nil {
+		s.clients = make(map[chan []byte]struct{})
+	}
+	s.clients[ch] = struct{}{}
+	if s.last != nil {
+		ch <- s.last
+	}
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.clients, ch)
+	}
+}
+
+// ServeHTTP serves the frames as a multipart/x-mixed-replace stream of
+// JPEGs, which browsers show as a moving image, until the client goes
+// away.
+func (s *frameStream) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	frames, cancel := s.subscribe()
+	defer cancel()
+	mw := multipart.NewWriter(w)
+	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+mw.Boundary())
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher, _ := w.(http.Flusher)
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case frame := <-frames:
+			part, err := mw.CreatePart(textproto.MIMEHeader{
+				"Content-Type":   {"image/jpeg"},
+				"Content-Length": {strconv.Itoa(len(frame))},
+			})
+			if err != nil {
+				return
+			}
+			if _, err := part.Write(frame); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// upscale returns src scaled up by the whole factor k, each pixel becoming
+// a k×k block.
+func upscale(src *image.RGBA, k int) *image.RGBA {
+	if k <= 1 {
+		return src
+	}
+	b := src.Rect
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx()*k, b.Dy()*k))
+	for y := 0; y < b.Dy(); y++ {
+		row := src.Pix[src.PixOffset(b.Min.X, b.Min.Y+y):]
+		line := dst.Pix[dst.PixOffset(0, y*k):dst.PixOffset(0, y*k+1)]
+		for x := 0; x < b.Dx(); x++ {
+			for i := 0; i < k; i++ {
+				copy(line[4*(x*k+i):], row[4*x:4*x+4])
+			}
+		}
+		for i := 1; i < k; i++ {
+			copy(dst.Pix[dst.PixOffset(0, y*k+i):], line)
+		}
+	}
+	return dst
+}
+
+// renderStill draws the simulation as it is now without a window, at
+// scale pixels per cell, and returns a copy of the frame.
+func (r *Renderer) renderStill(scale int) *image.RGBA {
+	r.renderFrame(r.palette(), false)
+	frame := r.dc.Image().(*image.RGBA)
+	img := image.NewRGBA(frame.Rect)
+	copy(img.Pix, frame.Pix)
+	return upscale(img, scale)
+}
+
+// runStream runs sim without a window and serves the frames as an MJPEG
+// stream over HTTP on addr, at / as a page and at /stream as the stream
+// itself, so that it can be watched in a browser while running on a
+// server. Regular builds need a display to start even so; builds made with
+// -tags ebitencbackend don't, see headless. It runs until the simulation
+// asks to shut down, or the server fails.
+func runStream(addr string, sim Simulation, r *Renderer) error {
+	stream := &frameStream{}
+	mux := http.NewServeMux()
+	mux.Handle("/stream", stream)
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/" {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, streamPage)
+	})
+	errc := make(chan error, 1)
+	go func() {
+		errc <- http.ListenAndServe(addr, mux)
+	}()
+	log.Printf("streaming on http://%s/", addr)
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	var buf bytes.Buffer
+	for !r.shutdown.Load().(bool) {
+		select {
+		case err := <-errc:
+			return err
+		case edit := <-r.edits:
+			r.change(edit)
+			continue
+		case t := <-ticker.C:
+			var img *image.RGBA
+			r.change(func() {
+				if !r.Paused() {
+					sim.Update(&t)
+					r.afterUpdate()
+				}
+				img = r.renderStill(r.cellSize)
+			})
+			buf.Reset()
+			if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: streamQuality}); err != nil {
+				return err
+			}
+			stream.publish(append([]byte(nil), buf.Bytes()...))
+		}
+	}
+	return nil
+}
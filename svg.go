@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"html"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// hexLayout returns the radius of the hexagons of a Hexago grid of
+// rows×cols drawn on a width×height canvas, and the margins that centre
+// them on it. It mirrors MakeHexGridWithContext, which keeps the margins to
+// itself.
+func hexLayout(width, height float64, rows, cols int) (radius, marginX, marginY float64) {
+	r, c := float64(rows), float64(cols)
+	rWidth := 2 * width / (3*c + 1)
+	rHeight := 2 * height / (math.Sqrt(3) * math.Sqrt(4*r*r+4*r+1))
+	radius = math.Min(rWidth, rHeight)
+	if rHeight > rWidth {
+		marginY = (height - (0.5+r)*math.Sqrt(3*rWidth*rWidth)) / 2
+	} else {
+		marginX = (width - (c/2*3*rHeight + rHeight/2)) / 2
+	}
+	return radius, marginX, marginY
+}
+
+// hexPath returns the SVG path of the hexagon in row i and column j of a
+// Hexago grid laid out by hexLayout, the way DrawGrid draws it: flat side
+// up, odd columns half a hexagon higher than even ones.
+func hexPath(i, j int, radius, marginX, marginY float64) string {
+	height := math.Sqrt(3 * radius * radius)
+	x := marginX + radius + float64(j)*1.5*radius
+	y := marginY + height + height*float64(i)
+	if j%2 == 1 {
+		y -= height / 2
+	}
+	var sb strings.Builder
+	for k := 0; k < 6; k++ {
+		a := -math.Pi/3 + float64(k)*math.Pi/3
+		cmd := 'L'
+		if k == 0 {
+			cmd = 'M'
+		}
+		fmt.Fprintf(&sb, "%c%.2f %.2f", cmd, x+radius*math.Cos(a), y+radius*math.Sin(a))
+	}
+	sb.WriteByte('Z')
+	return sb.String()
+}
+
+// svgColor returns c as an SVG paint and its opacity.
+func svgColor(c color.Color) (paint string, opacity float64) {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("#%02x%02x%02x", n.R, n.G, n.B), float64(n.A) / 0xff
+}
+
+// svgShapes collects the shapes of an SVG drawing by color, so that every
+// color is written once for the group of shapes it fills.
+type svgShapes struct {
+	shapes map[color.Color][]string
+	order  []color.Color
+}
+
+// add adds an SVG element to draw in c.
+func (s *svgShapes) add(c color.Color, elem string) {
+	if s.shapes == nil {
+		s.shapes = make(map[color.Color][]string)
+	}
+	if _, ok := s.shapes[c]; !ok {
+		s.order = append(s.order, c)
+	}
+	s.shapes[c] = append(s.shapes[c], elem)
+}
+
+// write writes a group of shapes for every color, in the order the colors
+// were first used, but with the most used first, so that the rarer colors
+// of walls, turmites and the like end up on top.
+func (s *svgShapes) write(w io.Writer) {
+	sort.SliceStable(s.order, func(i, j int) bool { return len(s.shapes[s.order[i]]) > len(s.shapes[s.order[j]]) })
+	for _, c := range s.order {
+		paint, opacity := svgColor(c)
+		if opacity == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "<g fill=\"%s\"", paint)
+		if opacity < 1 {
+			fmt.Fprintf(w, " fill-opacity=\"%.3f\"", opacity)
+		}
+		fmt.Fprintln(w, ">")
+		for _, e := range s.shapes[c] {
+			fmt.Fprintln(w, e)
+		}
+		fmt.Fprintln(w, "</g>")
+	}
+}
+
+// writeSVG writes the current generation of w as an SVG drawing, with one
+// shape for every cell that isn't dead, colored from p, over the background
+// and the hexagon grid the frame shows. Square grids are drawn with a unit
+// square per cell, scaled by the renderer's cell size; hexagonal worlds
+// with the hexagons drawHex lays out on the frame, and triangular ones
+// with the triangles of drawTriangles, without a grid.
+func (r *Renderer) writeSVG(out io.Writer, w *World, p palette) error {
+	width, height := r.dc.Width(), r.dc.Height()
+	var cells svgShapes
+	var add func(x, y int, c color.Color)
+	var gridPath strings.Builder
+	var radius, marginX, marginY float64
+	if w.Grid() == HexGrid {
+		radius, marginX, marginY = hexLayout(float64(width), float64(height), w.height, w.width)
+		add = func(x, y int, c color.Color) {
+			if x >= 0 && y >= 0 && x < w.width && y < w.height {
+				cells.add(c, fmt.Sprintf("<path d=\"%s\"/>", hexPath(y, x, radius, marginX, marginY)))
+			}
+		}
+		for i := 0; i < w.height; i++ {
+			for j := 0; j < w.width; j++ {
+				gridPath.WriteString(hexPath(i, j, radius, marginX, marginY))
+			}
+		}
+	} else if w.Grid() == TriangleGrid {
+		add = func(x, y int, c color.Color) {
+			t := triangleCorners(x, y)
+			cells.add(c, fmt.Sprintf("<polygon points=\"%.2f,%.2f %.2f,%.2f %.2f,%.2f\"/>", t[0][0], t[0][1], t[1][0], t[1][1], t[2][0], t[2][1]))
+		}
+	} else {
+		add = func(x, y int, c color.Color) {
+			cells.add(c, fmt.Sprintf("<rect x=\"%d\" y=\"%d\" width=\"1\" height=\"1\"/>", x, y))
+		}
+		// The overlay DrawHexagonGrid draws over square grids.
+		radius, marginX, marginY = hexLayout(float64(width), float64(height), 16, 25)
+		for i := 0; i < 16; i++ {
+			for j := 0; j < 25; j++ {
+				gridPath.WriteString(hexPath(i, j, radius, marginX, marginY))
+			}
+		}
+	}
+	w.ForEachIn(w.bounds, func(x, y int, s State) {
+		if s != Dead {
+			add(x, y, p.color(s))
+		}
+	})
+	for i, f := range w.flags {
+		switch {
+		case f&CellWall != 0:
+			add(i%w.width, i/w.width, wallColor)
+		case f&CellImmortal != 0:
+			add(i%w.width, i/w.width, immortalColor)
+		}
+	}
+	for _, t := range w.turmites {
+		add(t.X, t.Y, turmiteColor)
+	}
+
+	bw := bufio.NewWriter(out)
+	scale := max(r.cellSize, 1)
+	fmt.Fprintf(bw, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\" shape-rendering=\"crispEdges\">\n", width*scale, height*scale, width, height)
+	fmt.Fprintf(bw, "<title>%s</title>\n", html.EscapeString(w.Summary()))
+	if paint, opacity := svgColor(p[Dead]); opacity > 0 {
+		fmt.Fprintf(bw, "<rect width=\"100%%\" height=\"100%%\" fill=\"%s\" fill-opacity=\"%.3f\"/>\n", paint, opacity)
+	}
+	if w.Grid() == SquareGrid {
+		r.writeSVGGrid(bw, gridPath.String())
+	}
+	cells.write(bw)
+	if w.Grid() == HexGrid {
+		// Hexagonal worlds draw their outlines over the cells.
+		r.writeSVGGrid(bw, gridPath.String())
+	}
+	fmt.Fprintln(bw, "</svg>")
+	return bw.Flush()
+}
+
+// writeSVGGrid writes the outlines of the hexagons in path in the grid
+// color of the theme, one pixel of the frame wide.
+func (r *Renderer) writeSVGGrid(w io.Writer, path string) {
+	paint, opacity := svgColor(r.theme.Grid)
+	if opacity == 0 {
+		return
+	}
+	fmt.Fprintf(w, "<path d=\"%s\" fill=\"none\" stroke=\"%s\" stroke-opacity=\"%.3f\" stroke-width=\"1\"/>\n", path, paint, opacity)
+}
+
+// errNotWorld is returned by saveSVG for simulations other than worlds.
+var errNotWorld = errors.New("only worlds can be exported as SVG")
+
+// saveSVG writes the current generation of the simulation, which must be a
+// World, as an SVG file called name.
+func (r *Renderer) saveSVG(name string, p palette) error {
+	w, ok := r.sim.(*World)
+	if !ok {
+		return errNotWorld
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := r.writeSVG(f, w, p); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
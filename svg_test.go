@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/fogleman/gg"
+)
+
+// TestWriteSVGTriangles checks that the cells of triangular worlds are
+// exported as triangles pointing the way they are drawn.
+func TestWriteSVGTriangles(t *testing.T) {
+	w := NewWorld(20, 10, 0)
+	w.Neighbourhood = TriangleEdge
+	w.Set(2, 2, true)
+	w.Set(3, 2, true)
+	w.updateBounds()
+	r := NewRenderer(w, gg.NewContext(screenWidth, screenHeight))
+	var buf bytes.Buffer
+	if err := r.writeSVG(&buf, w, r.palette()); err != nil {
+		t.Fatal(err)
+	}
+	svg := buf.String()
+	// (2, 2) points up and (3, 2) down.
+	for _, want := range []string{
+		`<polygon points="8.00,20.78 16.00,20.78 12.00,13.86"/>`,
+		`<polygon points="12.00,13.86 20.00,13.86 16.00,20.78"/>`,
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("SVG lacks %s:\n%s", want, svg)
+		}
+	}
+	if strings.Contains(svg, "<rect x=") || strings.Contains(svg, "<path") {
+		t.Errorf("SVG has squares or a grid:\n%s", svg)
+	}
+}
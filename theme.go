@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// Theme is the set of colors simulations are drawn in. Rules with colors
+// of their own, such as Wireworld, keep them and only take the background.
+type Theme struct {
+	// Background is the color of dead cells and Grid that of the hexagon
+	// grid drawn over it.
+	Background color.Color
+	Grid       color.Color
+	// Live is the color of live cells, and Decay the color decaying cells
+	// of rules with more than two states fade from.
+	Live  color.Color
+	Decay color.Color
+}
+
+// defaultTheme is the theme used unless -theme picks another.
+const defaultTheme = "classic"
+
+// themes are the built-in themes, by name.
+var themes = map[string]*Theme{
+	"classic": {
+		Background: color.Transparent,
+		Grid:       color.RGBA{0x4c, 0x4c, 0x4c, 0xff},
+		Live:       color.White,
+		Decay:      color.RGBA{0xff, 0x90, 0x20, 0xff},
+	},
+	"solarized": {
+		Background: color.RGBA{0x00, 0x2b, 0x36, 0xff},
+		Grid:       color.RGBA{0x07, 0x36, 0x42, 0xff},
+		Live:       color.RGBA{0xee, 0xe8, 0xd5, 0xff},
+		Decay:      color.RGBA{0xcb, 0x4b, 0x16, 0xff},
+	},
+	"neon": {
+		Background: color.RGBA{0x0a, 0x00, 0x14, 0xff},
+		Grid:       color.RGBA{0x2a, 0x0a, 0x4a, 0xff},
+		Live:       color.RGBA{0x39, 0xff, 0x14, 0xff},
+		Decay:      color.RGBA{0xff, 0x00, 0xff, 0xff},
+	},
+}
+
+// themeNames returns the names of the built-in themes in sorted order.
+func themeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupTheme returns the built-in theme with the given name.
+func LookupTheme(name string) (*Theme, error) {
+	if t, ok := themes[name]; ok {
+		return t, nil
+	}
+	return nil, fmt.Errorf("unknown theme %q", name)
+}
+
+// palette returns the palette of two-state rules in t.
+func (t *Theme) palette() palette {
+	return palette{Dead: t.Background, Alive: t.Live}
+}
+
+// onBackground returns a copy of p with a transparent Dead entry replaced
+// by the theme's background.
+func (t *Theme) onBackground(p palette) palette {
+	if p[Dead] != color.Transparent {
+		return p
+	}
+	p = append(palette(nil), p...)
+	p[Dead] = t.Background
+	return p
+}
+
+// rgbaFloats returns the components of c scaled to [0, 1], as Hexago takes
+// them.
+func rgbaFloats(c color.Color) (r, g, b, a float64) {
+	cr, cg, cb, ca := c.RGBA()
+	return float64(cr) / 0xffff, float64(cg) / 0xffff, float64(cb) / 0xffff, float64(ca) / 0xffff
+}
+
+// handleTheme switches to the next built-in theme when T is pressed.
+func (r *Renderer) handleTheme() {
+	if !inpututil.IsKeyJustPressed(ebiten.KeyT) {
+		return
+	}
+	names := themeNames()
+	next := names[0]
+	for i, name := range names {
+		if themes[name] == r.theme {
+			next = names[(i+1)%len(names)]
+		}
+	}
+	r.theme = themes[next]
+	r.redraw()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// maxTPS bounds the ticks per second the ] key raises the cap to.
+const maxTPS = 960
+
+// setTiming sets the ticks per second, see setTPS, and turns vsync on or
+// off.
+func (r *Renderer) setTiming(tps int, vsync bool) {
+	r.setTPS(tps)
+	ebiten.SetVsyncEnabled(vsync)
+}
+
+// setTPS caps Ebiten at tps ticks per second and has the world update loop
+// advance the world as often. With tps 0 the ticks are uncapped: Update
+// runs once per frame and the world advances as fast as it can.
+func (r *Renderer) setTPS(tps int) {
+	if tps <= 0 {
+		ebiten.SetMaxTPS(ebiten.UncappedTPS)
+	} else {
+		ebiten.SetMaxTPS(tps)
+	}
+	// Only the latest rate matters to the world update loop, so a rate it
+	// hasn't picked up yet is replaced.
+	select {
+	case <-r.worldTPS:
+	default:
+	}
+	r.worldTPS <- tps
+}
+
+// handleTiming halves and doubles the tick cap with [ and ], lifts it
+// altogether with U, and toggles vsync with V. The cap U lifts is restored
+// when it is pressed again.
+func (r *Renderer) handleTiming() {
+	tps := ebiten.MaxTPS()
+	uncapped := tps == ebiten.UncappedTPS
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyU):
+		if uncapped {
+			r.setTPS(r.tps)
+		} else {
+			r.tps = tps
+			r.setTPS(0)
+		}
+	case inpututil.IsKeyJustPressed(ebiten.KeyBracketLeft) && !uncapped && tps > 1:
+		r.setTPS(tps / 2)
+	case inpututil.IsKeyJustPressed(ebiten.KeyBracketRight) && !uncapped && tps < maxTPS:
+		r.setTPS(min(tps*2, maxTPS))
+	case inpututil.IsKeyJustPressed(ebiten.KeyV):
+		ebiten.SetVsyncEnabled(!ebiten.IsVsyncEnabled())
+	}
+}
+
+// timingSummary describes the tick cap and vsync setting next to the
+// measured ticks and frames per second, for the HUD.
+func timingSummary() string {
+	limit := fmt.Sprint(ebiten.MaxTPS())
+	if ebiten.MaxTPS() == ebiten.UncappedTPS {
+		limit = "uncapped"
+	}
+	vsync := "off"
+	if ebiten.IsVsyncEnabled() {
+		vsync = "on"
+	}
+	return fmt.Sprintf("%.1f TPS (%s, [ ] U), %.1f FPS, vsync %s (V)", ebiten.CurrentTPS(), limit, ebiten.CurrentFPS(), vsync)
+}
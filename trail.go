@@ -0,0 +1,45 @@
+package main
+
+import (
+	"image"
+	"image/color"
+)
+
+// drawTrail blends the phosphor trail under the cells the pixel path just
+// drew into img. Every pixel a cell was drawn on starts glowing for
+// trailFrames frames once the cell is gone, in the theme's Decay color
+// fading towards the background. Simulations drawn through gg get no
+// trail.
+func (r *Renderer) drawTrail(img *image.RGBA) {
+	if r.trailFrames <= 0 {
+		r.trail = nil
+		return
+	}
+	n := len(img.Pix) / 4
+	if len(r.trail) != n {
+		r.trail = make([]uint8, n)
+	}
+	frames := r.trailFrames
+	if frames > 0xff {
+		frames = 0xff
+	}
+	bg := r.background
+	c := color.RGBAModel.Convert(r.theme.Decay).(color.RGBA)
+	for i := range r.trail {
+		pix := img.Pix[4*i : 4*i+4]
+		if pix[0] != bg[4*i] || pix[1] != bg[4*i+1] || pix[2] != bg[4*i+2] || pix[3] != bg[4*i+3] {
+			// A cell is drawn here: it glows at full strength once it dies.
+			r.trail[i] = uint8(frames)
+			continue
+		}
+		if r.trail[i] == 0 {
+			continue
+		}
+		r.trail[i]--
+		f := uint32(r.trail[i]) * 256 / uint32(frames+1)
+		pix[0] = fade(c.R, pix[0], f)
+		pix[1] = fade(c.G, pix[1], f)
+		pix[2] = fade(c.B, pix[2], f)
+		pix[3] = fade(c.A, pix[3], f)
+	}
+}
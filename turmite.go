@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fogleman/gg"
+)
+
+// Direction is the heading of an agent on the grid.
+type Direction int
+
+const (
+	North Direction = iota
+	East
+	South
+	West
+)
+
+// turn returns the direction after turning right by the given number of
+// quarter turns; negative values turn left.
+func (d Direction) turn(quarters int) Direction {
+	return Direction(((int(d)+quarters)%4 + 4) % 4)
+}
+
+// delta returns the grid step of one move in direction d.
+func (d Direction) delta() (dx, dy int) {
+	switch d {
+	case North:
+		return 0, -1
+	case East:
+		return 1, 0
+	case South:
+		return 0, 1
+	default:
+		return -1, 0
+	}
+}
+
+// turmiteMove is one entry of a turmite's transition table: the color to
+// write into the current cell, how many quarter turns to the right to make,
+// and the state to continue in.
+type turmiteMove struct {
+	write State
+	turn  int
+	next  int
+}
+
+// TurmiteTable is the program of a turmite, a two-dimensional Turing
+// machine: for every (state, color) pair it says what to write, where to
+// turn and which state to enter before moving forward one cell.
+type TurmiteTable struct {
+	// moves is indexed by state, then by color.
+	moves  [][]turmiteMove
+	colors int
+}
+
+// LangtonsAnt turns right on dead cells and left on live ones, flipping the
+// cell each time.
+var LangtonsAnt = MustParseTurmite("RL")
+
+var turnNames = map[string]int{"N": 0, "R": 1, "U": 2, "L": -1}
+
+// ParseTurmite parses a turmite program. A string made only of the letters
+// L, R, N and U is a multi-color ant: it has a single state, and on a cell
+// of color i turns as the i-th letter says and advances the cell to color
+// i+1. Anything else is read as a transition table, one transition per line:
+//
+//	# state color  write turn next
+//	0 0  1 R 0
+//	0 1  0 L 0
+//
+// where turn is one of N (none), R, U (u-turn) or L.
+func ParseTurmite(s string) (*TurmiteTable, error) {
+	if isTurnString(s) {
+		t := &TurmiteTable{colors: len(s), moves: make([][]turmiteMove, 1)}
+		for i, c := range strings.ToUpper(s) {
+			t.moves[0] = append(t.moves[0], turmiteMove{
+				write: State((i + 1) % len(s)),
+				turn:  turnNames[string(c)],
+			})
+		}
+		return t, nil
+	}
+	return readTurmite(strings.NewReader(s))
+}
+
+// MustParseTurmite is like ParseTurmite but panics if the program is
+// invalid.
+func MustParseTurmite(s string) *TurmiteTable {
+	t, err := ParseTurmite(s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// LoadTurmite returns the turmite described by spec, which is either a
+// multi-color ant string understood by ParseTurmite or the name of a file
+// holding a transition table.
+func LoadTurmite(spec string) (*TurmiteTable, error) {
+	if isTurnString(spec) {
+		return ParseTurmite(spec)
+	}
+	f, err := os.Open(spec)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	t, err := readTurmite(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", spec, err)
+	}
+	return t, nil
+}
+
+func isTurnString(s string) bool {
+	if len(s) < 2 {
+		return false
+	}
+	for _, c := range strings.ToUpper(s) {
+		if _, ok := turnNames[string(c)]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// readTurmite reads a transition table in the line format described by
+// ParseTurmite. Every (state, color) pair up to the largest state and color
+// mentioned must be defined.
+func readTurmite(r io.Reader) (*TurmiteTable, error) {
+	type key struct{ state, color int }
+	moves := make(map[key]turmiteMove)
+	states, colors := 0, 0
+
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := sc.Text()
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+		f := strings.Fields(text)
+		if len(f) == 0 {
+			continue
+		}
+		if len(f) != 5 {
+			return nil, fmt.Errorf("line %d: expected state, color, write, turn and next", line)
+		}
+		var n [4]int
+		for i, j := range []int{0, 1, 2, 4} {
+			v, err := strconv.Atoi(f[j])
+			if err != nil || v < 0 || v > 255 {
+				return nil, fmt.Errorf("line %d: invalid number %q", line, f[j])
+			}
+			n[i] = v
+		}
+		turn, ok := turnNames[strings.ToUpper(f[3])]
+		if !ok {
+			return nil, fmt.Errorf("line %d: invalid turn %q", line, f[3])
+		}
+		k := key{n[0], n[1]}
+		if _, dup := moves[k]; dup {
+			return nil, fmt.Errorf("line %d: duplicate transition for state %d, color %d", line, k.state, k.color)
+		}
+		moves[k] = turmiteMove{write: State(n[2]), turn: turn, next: n[3]}
+		states = max(states, max(n[0], n[3])+1)
+		colors = max(colors, max(n[1], n[2])+1)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(moves) == 0 {
+		return nil, fmt.Errorf("no transitions")
+	}
+
+	t := &TurmiteTable{colors: colors, moves: make([][]turmiteMove, states)}
+	for s := range t.moves {
+		t.moves[s] = make([]turmiteMove, colors)
+		for c := range t.moves[s] {
+			m, ok := moves[key{s, c}]
+			if !ok {
+				return nil, fmt.Errorf("missing transition for state %d, color %d", s, c)
+			}
+			t.moves[s][c] = m
+		}
+	}
+	return t, nil
+}
+
+// Colors returns the number of cell colors the turmite reads and writes.
+func (t *TurmiteTable) Colors() int {
+	return t.colors
+}
+
+// Turmite is an agent walking the grid according to a TurmiteTable.
+type Turmite struct {
+	X, Y    int
+	Heading Direction
+	state   int
+	table   *TurmiteTable
+}
+
+// turmiteColor is the color turmites are drawn with.
+var turmiteColor = color.RGBA{0xff, 0x30, 0x30, 0xff}
+
+// AddTurmite places a new turmite running table at (x, y) facing heading.
+func (w *World) AddTurmite(table *TurmiteTable, x, y int, heading Direction) {
+	w.turmites = append(w.turmites, &Turmite{X: x, Y: y, Heading: heading, table: table})
+}
+
+// AddAnt places a new Langton's ant at (x, y) facing heading.
+func (w *World) AddAnt(x, y int, heading Direction) {
+	w.AddTurmite(LangtonsAnt, x, y, heading)
+}
+
+// addRandomTurmites places n turmites running table: a single one starts in
+// the center facing north, more are scattered randomly.
+func (w *World) addRandomTurmites(table *TurmiteTable, n int) {
+	if n == 1 {
+		w.AddTurmite(table, w.width/2, w.height/2, North)
+		return
+	}
+	for i := 0; i < n; i++ {
+		w.AddTurmite(table, w.rng.Intn(w.width), w.rng.Intn(w.height), Direction(w.rng.Intn(4)))
+	}
+}
+
+// turmiteColors returns the largest number of colors used by any turmite
+// in the world.
+func (w *World) turmiteColors() int {
+	n := 0
+	for _, t := range w.turmites {
+		n = max(n, t.table.colors)
+	}
+	return n
+}
+
+// updateTurmites moves every turmite AntSteps times. Turmites always wrap
+// around the edges of the world, whatever the boundary mode. Cell states
+// beyond a turmite's colors are read modulo its number of colors.
+func (w *World) updateTurmites() {
+	for i := 0; i < w.AntSteps; i++ {
+		for _, t := range w.turmites {
+			c := t.Y*w.width + t.X
+			m := t.table.moves[t.state][int(w.area[c])%t.table.colors]
+			w.set(c, m.write)
+			t.state = m.next
+			t.Heading = t.Heading.turn(m.turn)
+			dx, dy := t.Heading.delta()
+			t.X = (t.X + dx + w.width) % w.width
+			t.Y = (t.Y + dy + w.height) % w.height
+		}
+	}
+}
+
+// drawTurmites marks every turmite with a small square so it stands out
+// from the cells around it.
+func (w *World) drawTurmites(dc *gg.Context) {
+	if len(w.turmites) == 0 {
+		return
+	}
+	dc.SetColor(turmiteColor)
+	for _, t := range w.turmites {
+		dc.DrawRectangle(float64(t.X-1), float64(t.Y-1), 3, 3)
+	}
+	dc.Fill()
+}
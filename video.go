@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// videoBacklog is how many captured frames can wait for ffmpeg before the
+// recorder starts dropping them, rather than hold up Draw.
+const videoBacklog = 8
+
+// videoFormats are the containers videos can be recorded in, and the
+// ffmpeg arguments that encode them. Both codecs want even dimensions, so
+// odd screen sizes are padded by a pixel.
+var videoFormats = map[string][]string{
+	"mp4":  {"-c:v", "libx264", "-preset", "veryfast", "-pix_fmt", "yuv420p", "-vf", "pad=ceil(iw/2)*2:ceil(ih/2)*2"},
+	"webm": {"-c:v", "libvpx-vp9", "-deadline", "realtime", "-pix_fmt", "yuv420p", "-vf", "pad=ceil(iw/2)*2:ceil(ih/2)*2"},
+}
+
+// videoFormatNames returns the names of videoFormats in sorted order.
+func videoFormatNames() []string {
+	names := make([]string, 0, len(videoFormats))
+	for name := range videoFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// videoRecorder records the screen as a video by piping raw RGBA frames
+// into the stdin of an ffmpeg process, which does the encoding. Draw
+// captures every frame and hands it to a writer goroutine, so that neither
+// the pipe nor ffmpeg stall the game loop.
+type videoRecorder struct {
+	// FFmpeg is the ffmpeg executable, looked up in PATH unless it is a
+	// path, and Format one of videoFormats.
+	FFmpeg string
+	Format string
+
+	// frames is the queue of the writer, nil while not recording, and
+	// size the size of the frames it takes.
+	frames  chan *image.RGBA
+	size    image.Point
+	count   int
+	dropped int
+	// free are captured frames the writer is done with, for reuse.
+	free chan *image.RGBA
+	// failed is closed by the writer if ffmpeg quits before recording
+	// stops.
+	failed chan struct{}
+	// results reports the outcome of every recording once ffmpeg exits.
+	results recordingResults
+}
+
+// recording reports whether frames are being recorded.
+func (v *videoRecorder) recording() bool {
+	return v.frames != nil
+}
+
+// start starts ffmpeg writing a video of size×size frames at fps frames per
+// second to the file name.
+func (v *videoRecorder) start(name string, size image.Point, fps int) error {
+	path, err := exec.LookPath(v.FFmpeg)
+	if err != nil {
+		return fmt.Errorf("%s not found, install ffmpeg or point -ffmpeg at it", v.FFmpeg)
+	}
+	args := []string{
+		"-hide_banner", "-loglevel", "error", "-y",
+		"-f", "rawvideo", "-pixel_format", "rgba",
+		"-video_size", fmt.Sprintf("%dx%d", size.X, size.Y),
+		"-framerate", strconv.Itoa(fps),
+		"-i", "-",
+	}
+	args = append(append(args, videoFormats[v.Format]...), name)
+	cmd := exec.Command(path, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	if v.free == nil {
+		v.free = make(chan *image.RGBA, videoBacklog)
+	}
+	v.frames = make(chan *image.RGBA, videoBacklog)
+	v.failed = make(chan struct{})
+	v.size, v.count, v.dropped = size, 0, 0
+	go func(frames <-chan *image.RGBA, failed chan struct{}) {
+		var werr error
+		written := 0
+		for img := range frames {
+			if werr == nil {
+				if _, werr = stdin.Write(img.Pix); werr != nil {
+					close(failed)
+				} else {
+					written++
+				}
+			}
+			select {
+			case v.free <- img:
+			default:
+			}
+		}
+		stdin.Close()
+		err := cmd.Wait()
+		switch {
+		case err != nil:
+			msg := strings.TrimSpace(stderr.String())
+			if i := strings.LastIndexByte(msg, '\n'); i >= 0 {
+				msg = msg[i+1:]
+			}
+			if msg == "" {
+				msg = err.Error()
+			}
+			v.results.report("ffmpeg failed on %s: %s", name, msg)
+		case werr != nil:
+			v.results.report("ffmpeg failed on %s: %v", name, werr)
+		default:
+			v.results.report("saved %s, %d frames", name, written)
+		}
+	}(v.frames, v.failed)
+	return nil
+}
+
+// stop stops recording, leaving ffmpeg to finish the file.
+func (v *videoRecorder) stop() {
+	close(v.frames)
+	v.frames = nil
+}
+
+// recordingResults collects the outcomes of recordings as the goroutines
+// finishing them report them, for the game loop to show one by one.
+// Reporting never blocks, however many recordings finish before the game
+// loop gets to them.
+type recordingResults struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+// report adds the outcome of a recording.
+func (q *recordingResults) report(format string, args ...interface{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.msgs = append(q.msgs, fmt.Sprintf(format, args...))
+}
+
+// take returns the oldest outcome not taken yet, if there is one.
+func (q *recordingResults) take() (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.msgs) == 0 {
+		return "", false
+	}
+	msg := q.msgs[0]
+	q.msgs = q.msgs[1:]
+	return msg, true
+}
+
+// errScreenResized is returned by the recorders when the screen no longer
+// has the size recording started at.
+var errScreenResized = errors.New("the screen was resized")
+
+// capture sends screen to ffmpeg if recording, or drops the frame if it has
+// fallen behind. It stops recording and returns an error if ffmpeg has quit
+// or the screen changed size, which a raw video stream can't follow.
+func (v *videoRecorder) capture(screen *ebiten.Image) error {
+	if !v.recording() {
+		return nil
+	}
+	select {
+	case <-v.failed:
+		v.stop()
+		return nil
+	default:
+	}
+	if screen.Bounds().Size() != v.size {
+		v.stop()
+		return errScreenResized
+	}
+	v.count++
+	if len(v.frames) == cap(v.frames) {
+		v.dropped++
+		return nil
+	}
+	var dst *image.RGBA
+	select {
+	case dst = <-v.free:
+	default:
+	}
+	v.frames <- captureScreen(screen, dst)
+	return nil
+}
+
+// captureVideo records the screen if a video is being recorded, with a
+// toast if recording had to stop.
+func (r *Renderer) captureVideo(screen *ebiten.Image) {
+	if err := r.video.capture(screen); err != nil {
+		r.showToast("video stopped: %v", err)
+	}
+}
+
+// handleVideo starts recording a video with F9 and stops it with F10, and
+// shows a toast once ffmpeg has written it. The video runs at the frame
+// rate the game is drawn at when it starts.
+func (r *Renderer) handleVideo() {
+	v := &r.video
+	if msg, ok := v.results.take(); ok {
+		r.showToast("%s", msg)
+	}
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyF9) && !v.recording():
+		fps := max(int(math.Round(ebiten.CurrentFPS())), 1)
+		if err := v.start(r.captureName("."+v.Format), r.screen, fps); err != nil {
+			r.showToast("can't record video: %v", err)
+			return
+		}
+		r.showToast("recording video, F10 to stop")
+	case inpututil.IsKeyJustPressed(ebiten.KeyF10) && v.recording():
+		v.stop()
+		r.showToast("encoding video")
+	}
+}
+
+// videoSummary describes the video being recorded for the HUD, or returns
+// "" if there is none.
+func (r *Renderer) videoSummary() string {
+	v := &r.video
+	if !v.recording() {
+		return ""
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "recording %s video, %d frames", v.Format, v.count-v.dropped)
+	if v.dropped > 0 {
+		fmt.Fprintf(&sb, ", %d dropped", v.dropped)
+	}
+	sb.WriteString(", F10 to stop")
+	return sb.String()
+}
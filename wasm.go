@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// WasmRule is a rule implemented by a WebAssembly module, so that rules can
+// be written in any language that compiles to WebAssembly. The module must
+// export a function
+//
+//	next(state i32, neighbours i32) i32
+//
+// where neighbours is a bit mask of the live neighbours, bit i being set if
+// the i-th neighbour in the order of the world's neighbourhood is Alive,
+// and which returns the cell's next state. It may also export
+//
+//	states() i32
+//
+// returning the number of states it uses. The module must not import
+// anything. Whenever the file changes, the module is reloaded at the start
+// of the next tick, so rules can be swapped while the demo runs.
+type WasmRule struct {
+	filename string
+	modTime  time.Time
+	runtime  wazero.Runtime
+	next     api.Function
+	states   int
+	// cache holds the results of next, which must be pure, by state and
+	// neighbour mask.
+	cache map[uint64]State
+}
+
+// LoadWasmRule loads the WebAssembly rule module in the named file.
+func LoadWasmRule(filename string) (*WasmRule, error) {
+	r := &WasmRule{filename: filename}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// load (re)instantiates the module from the file, replacing the current
+// one only if that succeeds.
+func (r *WasmRule) load() error {
+	fi, err := os.Stat(r.filename)
+	if err != nil {
+		return err
+	}
+	bin, err := os.ReadFile(r.filename)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	rt := wazero.NewRuntime(ctx)
+	mod, err := rt.Instantiate(ctx, bin)
+	if err != nil {
+		rt.Close(ctx)
+		return fmt.Errorf("wasm rule %s: %v", r.filename, err)
+	}
+	next := mod.ExportedFunction("next")
+	if next == nil {
+		rt.Close(ctx)
+		return fmt.Errorf("wasm rule %s: no next function exported", r.filename)
+	}
+	states := 2
+	if fn := mod.ExportedFunction("states"); fn != nil {
+		res, err := fn.Call(ctx)
+		if err != nil || len(res) != 1 {
+			rt.Close(ctx)
+			return fmt.Errorf("wasm rule %s: states: %v", r.filename, err)
+		}
+		states = int(int32(res[0]))
+	}
+	if states < 2 || states > 256 {
+		rt.Close(ctx)
+		return fmt.Errorf("wasm rule %s: states must be between 2 and 256", r.filename)
+	}
+
+	if r.runtime != nil {
+		r.runtime.Close(ctx)
+	}
+	r.modTime = fi.ModTime()
+	r.runtime = rt
+	r.next = next
+	r.states = states
+	r.cache = make(map[uint64]State)
+	return nil
+}
+
+// States returns the number of cell states of the rule.
+func (r *WasmRule) States() int {
+	return r.states
+}
+
+// beginTick implements tickingRule by reloading the module if its file
+// has changed. A module that fails to load is logged and the previous one
+// kept.
+func (r *WasmRule) beginTick() {
+	fi, err := os.Stat(r.filename)
+	if err != nil || fi.ModTime().Equal(r.modTime) {
+		return
+	}
+	if err := r.load(); err != nil {
+		log.Print(err)
+		r.modTime = fi.ModTime()
+		return
+	}
+	log.Printf("reloaded wasm rule %s", r.filename)
+}
+
+// Next implements Rule.
+func (r *WasmRule) Next(self State, neighbours []State) State {
+	var mask uint32
+	for i, s := range neighbours {
+		if s == Alive {
+			mask |= 1 << i
+		}
+	}
+	key := uint64(self)<<32 | uint64(mask)
+	if next, ok := r.cache[key]; ok {
+		return next
+	}
+	next := Dead
+	res, err := r.next.Call(context.Background(), uint64(self), uint64(mask))
+	if err != nil {
+		log.Printf("wasm rule %s: %v", r.filename, err)
+	} else if s := int32(res[0]); 0 <= s && int(s) < r.states {
+		next = State(s)
+	}
+	r.cache[key] = next
+	return next
+}
+
+// String returns the name of the module file.
+func (r *WasmRule) String() string {
+	return r.filename
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+// WaTorParams are the parameters of a Wa-Tor world.
+type WaTorParams struct {
+	// Fish and Sharks are the fractions of cells initially holding fish and
+	// sharks.
+	Fish, Sharks float64
+	// FishBreed and SharkBreed are the number of ticks a creature has to
+	// survive before it can breed.
+	FishBreed, SharkBreed int
+	// SharkEnergy is the energy a shark starts with, and Gain what it gains
+	// by eating a fish. A shark loses one unit each tick and starves when
+	// it runs out.
+	SharkEnergy, Gain int
+}
+
+// DefaultWaTorParams give populations that oscillate for a long time
+// without either species dying out.
+var DefaultWaTorParams = WaTorParams{
+	Fish:        0.3,
+	Sharks:      0.05,
+	FishBreed:   3,
+	SharkBreed:  10,
+	SharkEnergy: 3,
+	Gain:        3,
+}
+
+// ParseWaTorParams parses parameters written as comma-separated key=value
+// pairs, e.g. "fish=0.3,sharks=0.05,fbreed=3,sbreed=10,energy=3,gain=3".
+// Missing keys keep their default values.
+func ParseWaTorParams(s string) (WaTorParams, error) {
+	p := DefaultWaTorParams
+	if strings.TrimSpace(s) == "" {
+		return p, nil
+	}
+	for _, f := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(f), "=", 2)
+		if len(kv) != 2 {
+			return p, fmt.Errorf("wa-tor parameters %q: expected key=value, got %q", s, f)
+		}
+		var err error
+		switch strings.ToLower(kv[0]) {
+		case "fish":
+			p.Fish, err = strconv.ParseFloat(kv[1], 64)
+		case "sharks":
+			p.Sharks, err = strconv.ParseFloat(kv[1], 64)
+		case "fbreed":
+			p.FishBreed, err = strconv.Atoi(kv[1])
+		case "sbreed":
+			p.SharkBreed, err = strconv.Atoi(kv[1])
+		case "energy":
+			p.SharkEnergy, err = strconv.Atoi(kv[1])
+		case "gain":
+			p.Gain, err = strconv.Atoi(kv[1])
+		default:
+			err = fmt.Errorf("unknown parameter")
+		}
+		if err != nil {
+			return p, fmt.Errorf("wa-tor parameters %q: %s: %v", s, f, err)
+		}
+	}
+	if p.Fish < 0 || p.Sharks < 0 || p.Fish+p.Sharks > 1 {
+		return p, fmt.Errorf("wa-tor parameters %q: fish and shark densities must add up to at most 1", s)
+	}
+	if p.FishBreed < 1 || p.SharkBreed < 1 || p.SharkEnergy < 1 || p.Gain < 0 {
+		return p, fmt.Errorf("wa-tor parameters %q: breeding times and energy must be positive", s)
+	}
+	return p, nil
+}
+
+type creatureKind uint8
+
+const (
+	fish creatureKind = iota
+	shark
+)
+
+// creature is a fish or shark living in a Wa-Tor cell.
+type creature struct {
+	kind creatureKind
+	// age counts the ticks since the creature was born or last bred.
+	age    int
+	energy int
+	// moved is the tick in which the creature last moved, so that a
+	// creature moving ahead of the scan isn't moved twice in one tick.
+	moved int
+}
+
+var (
+	fishColor = color.RGBA{0x3a, 0xa8, 0xe0, 0xff}
+	// sharkGradient shades sharks from starving to well fed.
+	sharkGradient = []color.RGBA{
+		{0x50, 0x10, 0x10, 0xff},
+		{0xff, 0x50, 0x40, 0xff},
+	}
+)
+
+// WaTorWorld is Dewdney's Wa-Tor predator-prey simulation on a toroidal
+// ocean. Fish swim about at random and breed; sharks hunt the fish next to
+// them, breed, and starve if they go too long without eating.
+type WaTorWorld struct {
+	cells  []*creature
+	width  int
+	height int
+	scale  int
+	params WaTorParams
+	tick   int
+	rng    *rand.Rand
+	// order is the scratch permutation cells are visited in.
+	order []int
+}
+
+// NewWaTorWorld creates a width×height Wa-Tor world, each cell drawn as a
+// scale×scale square, stocked with fish and sharks at random.
+func NewWaTorWorld(width, height, scale int, params WaTorParams) *WaTorWorld {
+	w := &WaTorWorld{
+		cells:  make([]*creature, width*height),
+		width:  width,
+		height: height,
+		scale:  scale,
+		params: params,
+		rng:    newRand(),
+	}
+	for i := range w.cells {
+		switch r := w.rng.Float64(); {
+		case r < params.Fish:
+			w.cells[i] = &creature{kind: fish, age: w.rng.Intn(params.FishBreed)}
+		case r < params.Fish+params.Sharks:
+			w.cells[i] = &creature{kind: shark, age: w.rng.Intn(params.SharkBreed), energy: params.SharkEnergy}
+		}
+	}
+	return w
+}
+
+// Populations returns the number of fish and sharks.
+func (w *WaTorWorld) Populations() (fishes, sharks int) {
+	for _, c := range w.cells {
+		switch {
+		case c == nil:
+		case c.kind == fish:
+			fishes++
+		default:
+			sharks++
+		}
+	}
+	return fishes, sharks
+}
+
+// neighbours appends to dst the indices of the von Neumann neighbours of
+// cell i for which match returns true.
+func (w *WaTorWorld) neighbours(dst []int, i int, match func(*creature) bool) []int {
+	x, y := i%w.width, i/w.width
+	for _, o := range vonNeumannOffsets {
+		x2 := (x + o[0] + w.width) % w.width
+		y2 := (y + o[1] + w.height) % w.height
+		j := y2*w.width + x2
+		if match(w.cells[j]) {
+			dst = append(dst, j)
+		}
+	}
+	return dst
+}
+
+func isEmpty(c *creature) bool {
+	return c == nil
+}
+
+func isFish(c *creature) bool {
+	return c != nil && c.kind == fish
+}
+
+// Update moves every creature once, visiting them in random order.
+func (w *WaTorWorld) Update(t *time.Time) {
+	w.tick++
+	if w.order == nil {
+		w.order = make([]int, len(w.cells))
+		for i := range w.order {
+			w.order[i] = i
+		}
+	}
+	w.rng.Shuffle(len(w.order), func(i, j int) {
+		w.order[i], w.order[j] = w.order[j], w.order[i]
+	})
+	var candidates []int
+	for _, i := range w.order {
+		c := w.cells[i]
+		if c == nil || c.moved == w.tick {
+			continue
+		}
+		c.moved = w.tick
+		c.age++
+
+		breed := w.params.FishBreed
+		if c.kind == shark {
+			breed = w.params.SharkBreed
+			c.energy--
+			candidates = w.neighbours(candidates[:0], i, isFish)
+			if len(candidates) > 0 {
+				c.energy += w.params.Gain
+			} else if c.energy <= 0 {
+				w.cells[i] = nil
+				continue
+			}
+		}
+		if c.kind == fish || len(candidates) == 0 {
+			candidates = w.neighbours(candidates[:0], i, isEmpty)
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+
+		j := candidates[w.rng.Intn(len(candidates))]
+		w.cells[j] = c
+		w.cells[i] = nil
+		if c.age >= breed {
+			c.age = 0
+			child := &creature{kind: c.kind, moved: w.tick}
+			if c.kind == shark {
+				child.energy = w.params.SharkEnergy
+			}
+			w.cells[i] = child
+		}
+	}
+}
+
+// Draw paints fish blue and sharks red, dimmer the closer they are to
+// starving.
+func (w *WaTorWorld) Draw(dc *gg.Context, p palette) {
+	img, ok := dc.Image().(*image.RGBA)
+	if !ok {
+		return
+	}
+	full := float64(w.params.SharkEnergy + w.params.Gain)
+	for y := 0; y < w.height; y++ {
+		for x := 0; x < w.width; x++ {
+			cr := w.cells[y*w.width+x]
+			if cr == nil {
+				continue
+			}
+			c := fishColor
+			if cr.kind == shark {
+				c = gradientColor(sharkGradient, float64(cr.energy)/full)
+			}
+			for py := y * w.scale; py < (y+1)*w.scale; py++ {
+				for px := x * w.scale; px < (x+1)*w.scale; px++ {
+					img.SetRGBA(px, py, c)
+				}
+			}
+		}
+	}
+}
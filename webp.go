@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	"math/bits"
+	"time"
+)
+
+// webpEncoder is the clipEncoder of animated WebP images. Every frame is
+// encoded as a VP8L lossless bitstream as it is added, and write wraps
+// them in the ANMF chunks of an animated WebP.
+//
+// The encoder is as simple as VP8L allows: no transforms, no color cache
+// and no backward references, just every pixel spelt out with a fixed
+// 8-bit code for each channel. The files are about as large as the raw
+// pixels, which is fine for short clips; the point is not to need any
+// tools beyond Go.
+type webpEncoder struct {
+	size   image.Point
+	frames []webpFrame
+}
+
+// webpFrame is a frame of a WebP clip: its VP8L bitstream and how long it
+// is shown in milliseconds.
+type webpFrame struct {
+	vp8l     []byte
+	duration int
+}
+
+// add implements clipEncoder.
+func (e *webpEncoder) add(img *image.RGBA, delay time.Duration) error {
+	size := img.Rect.Size()
+	if size.X > 1<<14 || size.Y > 1<<14 {
+		return errors.New("webp: frames are larger than 16384 pixels")
+	}
+	if len(e.frames) == 0 {
+		e.size = size
+	} else if size != e.size {
+		return errors.New("webp: frames differ in size")
+	}
+	e.frames = append(e.frames, webpFrame{
+		vp8l:     encodeVP8L(img),
+		duration: min(int(delay/time.Millisecond), 1<<24-1),
+	})
+	return nil
+}
+
+// write implements clipEncoder.
+func (e *webpEncoder) write(w io.Writer) error {
+	if len(e.frames) == 0 {
+		return errors.New("webp: no frames")
+	}
+	var body []byte
+	// The VP8X chunk only has the animation flag set, the frames are
+	// opaque.
+	vp8x := make([]byte, 10)
+	vp8x[0] = 0x02
+	putUint24(vp8x[4:], e.size.X-1)
+	putUint24(vp8x[7:], e.size.Y-1)
+	body = appendRIFFChunk(body, "VP8X", vp8x)
+	// A black background, and a loop count of 0 for looping forever.
+	body = appendRIFFChunk(body, "ANIM", []byte{0, 0, 0, 0xff, 0, 0})
+	for _, f := range e.frames {
+		anmf := make([]byte, 16, 16+8+len(f.vp8l)+1)
+		// The frame covers the whole canvas at offset 0, 0.
+		putUint24(anmf[6:], e.size.X-1)
+		putUint24(anmf[9:], e.size.Y-1)
+		putUint24(anmf[12:], f.duration)
+		// Don't blend the frame with the previous one, and don't dispose
+		// of it either.
+		anmf[15] = 0x02
+		anmf = appendRIFFChunk(anmf, "VP8L", f.vp8l)
+		body = appendRIFFChunk(body, "ANMF", anmf)
+	}
+
+	var header [12]byte
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(4+len(body)))
+	copy(header[8:], "WEBP")
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(body)
+	return err
+}
+
+// appendRIFFChunk appends a RIFF chunk of the given type and data to b,
+// padded to an even length.
+func appendRIFFChunk(b []byte, typ string, data []byte) []byte {
+	var header [8]byte
+	copy(header[:], typ)
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(data)))
+	b = append(append(b, header[:]...), data...)
+	if len(data)%2 == 1 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// putUint24 stores v in the first three bytes of b, little endian.
+func putUint24(b []byte, v int) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
+
+// bitWriter writes values of up to 32 bits least significant bit first,
+// the way VP8L bitstreams are read.
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits uint
+}
+
+// write writes the low n bits of v.
+func (bw *bitWriter) write(v uint32, n uint) {
+	bw.acc |= uint64(v) << bw.nbits
+	bw.nbits += n
+	for bw.nbits >= 8 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc >>= 8
+		bw.nbits -= 8
+	}
+}
+
+// bytes returns everything written, with the last byte padded by zeros.
+func (bw *bitWriter) bytes() []byte {
+	if bw.nbits > 0 {
+		bw.write(0, 8-bw.nbits)
+	}
+	return bw.buf
+}
+
+// writeFixedCode writes a normal prefix code over an alphabet of size
+// symbols in which the first 256 all have length 8 and the others length
+// 0, so that each of those is coded as its own value in 8 bits. The code
+// lengths are themselves coded with a code-length code that only has the
+// lengths 0 and 8, both 1 bit long.
+func (bw *bitWriter) writeFixedCode(size int) {
+	bw.write(0, 1) // A normal code, not a simple one.
+	// Code-length code lengths come in the order 17, 18, 0, 1, 2, 3, 4,
+	// 5, 16, 6, 7, 8, …, so 12 of them reach the length of 8.
+	bw.write(12-4, 4)
+	for i := 0; i < 12; i++ {
+		if i == 2 || i == 11 {
+			bw.write(1, 3)
+		} else {
+			bw.write(0, 3)
+		}
+	}
+	bw.write(0, 1) // Lengths follow for the whole alphabet.
+	// Canonical codes give length 0 the code 0 and length 8 the code 1.
+	for s := 0; s < size; s++ {
+		if s < 256 {
+			bw.write(1, 1)
+		} else {
+			bw.write(0, 1)
+		}
+	}
+}
+
+// encodeVP8L encodes img, which must be opaque, as a VP8L bitstream.
+func encodeVP8L(img *image.RGBA) []byte {
+	size := img.Rect.Size()
+	bw := &bitWriter{}
+	bw.write(0x2f, 8) // Signature.
+	bw.write(uint32(size.X-1), 14)
+	bw.write(uint32(size.Y-1), 14)
+	bw.write(0, 1) // No alpha.
+	bw.write(0, 3) // Version.
+	bw.write(0, 1) // No transforms.
+	bw.write(0, 1) // No color cache.
+	bw.write(0, 1) // A single group of prefix codes.
+
+	// Green and the length prefixes share an alphabet of 256+24 symbols,
+	// red, blue and alpha have 256 each.
+	bw.writeFixedCode(256 + 24)
+	bw.writeFixedCode(256)
+	bw.writeFixedCode(256)
+	bw.writeFixedCode(256)
+	// Distances are never used, so their code is a simple one of a single
+	// symbol, 0, which takes no bits.
+	bw.write(1, 1) // Simple code.
+	bw.write(0, 1) // One symbol.
+	bw.write(0, 1) // Of 1 bit.
+	bw.write(0, 1) // Symbol 0.
+
+	// Every channel of every pixel is a literal in its code. Prefix codes
+	// are read most significant bit first, hence the reversal.
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		pix := img.Pix[img.PixOffset(img.Rect.Min.X, y):]
+		for x := 0; x < size.X; x++ {
+			r, g, b := pix[4*x], pix[4*x+1], pix[4*x+2]
+			bw.write(uint32(bits.Reverse8(g)), 8)
+			bw.write(uint32(bits.Reverse8(r)), 8)
+			bw.write(uint32(bits.Reverse8(b)), 8)
+			bw.write(uint32(bits.Reverse8(0xff)), 8)
+		}
+	}
+	return bw.bytes()
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// kernelTap is one non-zero weight of a WeightedRule's kernel.
+type kernelTap struct {
+	dx, dy int
+	weight int32
+}
+
+// WeightedRule is a Life-like rule in which every neighbour position
+// contributes its own weight to the count. Birth and survival are defined
+// over the weighted sum of the live neighbours.
+type WeightedRule struct {
+	kernel [][]int
+	taps   []kernelTap
+	radius int
+	// birth and survival are indexed by sum-minSum.
+	birth, survival []bool
+	minSum          int
+}
+
+// ParseWeightedRule parses a weighted rule such as
+//
+//	W1,2,1;2,0,2;1,2,1/B5,6/S3..6
+//
+// The kernel follows the W as rows separated by semicolons, of weights
+// separated by commas; it must be square with an odd number of rows, and
+// its centre weights the cell itself. The birth and survival conditions
+// are lists of sums and ranges of sums.
+func ParseWeightedRule(s string) (*WeightedRule, error) {
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if len(parts) != 3 || !hasPrefixFold(parts[0], "W") || !hasPrefixFold(parts[1], "B") || !hasPrefixFold(parts[2], "S") {
+		return nil, fmt.Errorf("weighted rule %q: expected W<kernel>/B<sums>/S<sums>", s)
+	}
+	var kernel [][]int
+	for _, row := range strings.Split(parts[0][1:], ";") {
+		var weights []int
+		for _, f := range strings.Split(row, ",") {
+			v, err := strconv.Atoi(strings.TrimSpace(f))
+			if err != nil {
+				return nil, fmt.Errorf("weighted rule %q: bad weight %q", s, f)
+			}
+			weights = append(weights, v)
+		}
+		kernel = append(kernel, weights)
+	}
+	n := len(kernel)
+	for _, row := range kernel {
+		if len(row) != n {
+			return nil, fmt.Errorf("weighted rule %q: kernel must be square", s)
+		}
+	}
+	if n%2 == 0 {
+		return nil, fmt.Errorf("weighted rule %q: kernel must have an odd size", s)
+	}
+
+	r := &WeightedRule{kernel: kernel, radius: n / 2}
+	maxSum := 0
+	for y, row := range kernel {
+		for x, v := range row {
+			if v == 0 {
+				continue
+			}
+			r.taps = append(r.taps, kernelTap{x - r.radius, y - r.radius, int32(v)})
+			if v < 0 {
+				r.minSum += v
+			} else {
+				maxSum += v
+			}
+		}
+	}
+	var err error
+	if r.birth, err = r.parseSums(parts[1][1:], maxSum); err != nil {
+		return nil, fmt.Errorf("weighted rule %q: %v", s, err)
+	}
+	if r.survival, err = r.parseSums(parts[2][1:], maxSum); err != nil {
+		return nil, fmt.Errorf("weighted rule %q: %v", s, err)
+	}
+	return r, nil
+}
+
+// parseSums parses a comma separated list of sums and ranges of sums into
+// a set indexed by sum-minSum.
+func (r *WeightedRule) parseSums(s string, maxSum int) ([]bool, error) {
+	set := make([]bool, maxSum-r.minSum+1)
+	if s == "" {
+		return set, nil
+	}
+	for _, f := range strings.Split(s, ",") {
+		lo, hi, err := parseRange(f)
+		if err != nil {
+			return nil, err
+		}
+		for v := max(lo, r.minSum); v <= min(hi, maxSum); v++ {
+			set[v-r.minSum] = true
+		}
+	}
+	return set, nil
+}
+
+// Next implements Rule for the Moore neighbours in row-major order, which
+// is how neighbourStates gathers them; taps further out than that are left
+// out. World.Update computes the sums itself with kernelSums.
+func (r *WeightedRule) Next(self State, neighbours []State) State {
+	var sum int32
+	for _, t := range r.taps {
+		if t.dx == 0 && t.dy == 0 {
+			if self == Alive {
+				sum += t.weight
+			}
+			continue
+		}
+		if t.dx < -1 || t.dx > 1 || t.dy < -1 || t.dy > 1 {
+			continue
+		}
+		// The neighbours skip the cell itself, in the middle of the 3×3
+		// block.
+		i := (t.dy+1)*3 + t.dx + 1
+		if i > 4 {
+			i--
+		}
+		if i < len(neighbours) && neighbours[i] == Alive {
+			sum += t.weight
+		}
+	}
+	return r.NextSum(self, int(sum))
+}
+
+// NextSum returns the next state of a cell given its state and the weighted
+// sum of the live cells under the kernel.
+func (r *WeightedRule) NextSum(self State, sum int) State {
+	i := sum - r.minSum
+	if i < 0 || i >= len(r.birth) {
+		return Dead
+	}
+	if self == Alive && r.survival[i] || self != Alive && r.birth[i] {
+		return Alive
+	}
+	return Dead
+}
+
+// String returns the rule in the notation read by ParseWeightedRule.
+func (r *WeightedRule) String() string {
+	var sb strings.Builder
+	sb.WriteByte('W')
+	for y, row := range r.kernel {
+		if y > 0 {
+			sb.WriteByte(';')
+		}
+		for x, v := range row {
+			if x > 0 {
+				sb.WriteByte(',')
+			}
+			sb.WriteString(strconv.Itoa(v))
+		}
+	}
+	sums := func(set []bool) string {
+		var s []string
+		for i, ok := range set {
+			if ok {
+				s = append(s, strconv.Itoa(i+r.minSum))
+			}
+		}
+		return strings.Join(s, ",")
+	}
+	return fmt.Sprintf("%s/B%s/S%s", sb.String(), sums(r.birth), sums(r.survival))
+}
+
+// updateWeighted advances the world by one tick under a weighted rule.
+func (w *World) updateWeighted(rule *WeightedRule) {
+	sums := w.kernelSums(rule.taps, rule.radius)
+	next := w.nextBuffer()
+	for i, self := range w.area {
+		next[i] = rule.NextSum(self, int(sums[i]))
+		w.countChange(self, next[i])
+	}
+	w.recordChanges(w.area, next)
+	w.swap()
+}
+
+// kernelSums returns, for every cell, the sum of the weights of the taps
+// lying on live cells. Cells beyond the edges are resolved by the boundary
+// mode once, into a grid padded by radius on every side, so the taps can
+// then be read without any bounds checks. The sums are only valid until
+// the next call.
+func (w *World) kernelSums(taps []kernelTap, radius int) []int32 {
+	width, height := w.width, w.height
+	pw := width + 2*radius
+	live := zeroed(&w.scratch.padded, pw*(height+2*radius))
+	for py := 0; py < height+2*radius; py++ {
+		for px := 0; px < pw; px++ {
+			x2, y2, ok, alive := w.Boundary.resolve(width, height, px-radius, py-radius)
+			if ok && w.area[y2*width+x2] == Alive || !ok && alive {
+				live[py*pw+px] = 1
+			}
+		}
+	}
+	sums := zeroed(&w.scratch.sums, width*height)
+	for _, t := range taps {
+		for y := 0; y < height; y++ {
+			src := live[(y+radius+t.dy)*pw+radius+t.dx:]
+			dst := sums[y*width : (y+1)*width]
+			for x := range dst {
+				dst[x] += t.weight * src[x]
+			}
+		}
+	}
+	return sums
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestWeightedRuleNext checks that Next weights every Moore neighbour by
+// its own tap when some of the weights around the cell are zero.
+func TestWeightedRuleNext(t *testing.T) {
+	// The row-major weights of the eight neighbours; the top middle and
+	// bottom right ones are zero.
+	weights := [8]int{1, 0, 2, 4, 8, 16, 32, 0}
+	for want := 0; want <= 63; want++ {
+		r, err := ParseWeightedRule(fmt.Sprintf("W1,0,2;4,0,8;16,32,0/B%d/S", want))
+		if err != nil {
+			t.Fatal(err)
+		}
+		neighbours := make([]State, 8)
+		for c := 0; c < 256; c++ {
+			sum := 0
+			for i := range neighbours {
+				neighbours[i] = Dead
+				if c&(1<<i) != 0 {
+					neighbours[i] = Alive
+					sum += weights[i]
+				}
+			}
+			if got := r.Next(Dead, neighbours); (got == Alive) != (sum == want) {
+				t.Fatalf("B%d: neighbours %08b with sum %d give %v", want, c, sum, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !ebitencbackend
+
+package main
+
+// headless is false in regular builds, which need a display to start, see
+// headless.go.
+const headless = false
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/fogleman/gg"
+)
+
+// maxZones is the number of zones a world can be partitioned into, the
+// main rule's zone included; each can be picked with a digit key.
+const maxZones = 10
+
+// SetZoneRules partitions the world into zones running different rules.
+// Zone 0 runs the world's own rule and zone i the i-th of rules. The zones
+// start out as vertical bands of equal width and can be repainted with
+// PaintZone. Zone rules must decide a cell's next state from its immediate
+// neighbours alone, so block, Larger than Life and weighted rules can't be
+// used.
+func (w *World) SetZoneRules(rules []Rule) error {
+	if len(rules)+1 > maxZones {
+		return fmt.Errorf("%d zones, at most %d are supported", len(rules)+1, maxZones)
+	}
+	for _, r := range append([]Rule{w.rule}, rules...) {
+		switch r.(type) {
+		case *BlockRule, countingRule, *WeightedRule:
+			return fmt.Errorf("rule %v can't be used in zones", r)
+		}
+	}
+	w.zoneRules = rules
+	if len(rules) == 0 {
+		w.zones = nil
+		return nil
+	}
+	w.zones = make([]uint8, len(w.area))
+	bands := len(rules) + 1
+	for i := range w.zones {
+		w.zones[i] = uint8(i % w.width * bands / w.width)
+	}
+	return nil
+}
+
+// Zones returns the number of zones, 1 if the world isn't partitioned.
+func (w *World) Zones() int {
+	return len(w.zoneRules) + 1
+}
+
+// PaintZone assigns the cell at (x, y) to zone z. It does nothing for cells
+// outside the world or zones that don't exist.
+func (w *World) PaintZone(x, y, z int) {
+	if w.zones == nil || z < 0 || z >= w.Zones() || x < 0 || y < 0 || x >= w.width || y >= w.height {
+		return
+	}
+	w.zones[y*w.width+x] = uint8(z)
+}
+
+// ruleAt returns the rule governing cell i.
+func (w *World) ruleAt(i int) Rule {
+	if w.zones == nil || w.zones[i] == 0 {
+		return w.rule
+	}
+	return w.zoneRules[w.zones[i]-1]
+}
+
+// zoneTint returns the faint color zones other than zone 0 are tinted with.
+func zoneTint(z, zones int) color.Color {
+	r, g, b, _ := hsv(360*float64(z-1)/float64(zones-1), 0.8, 0.9).RGBA()
+	return color.NRGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), 0x20}
+}
+
+// drawZones tints every zone but zone 0, one rectangle per run of cells of
+// the same zone along a row.
+func (w *World) drawZones(dc *gg.Context) {
+	if w.zones == nil {
+		return
+	}
+	for y := 0; y < w.height; y++ {
+		row := w.zones[y*w.width : (y+1)*w.width]
+		for x := 0; x < w.width; {
+			z, x0 := row[x], x
+			for x < w.width && row[x] == z {
+				x++
+			}
+			if z != 0 {
+				dc.SetColor(zoneTint(int(z), w.Zones()))
+				dc.DrawRectangle(float64(x0), float64(y), float64(x-x0), 1)
+				dc.Fill()
+			}
+		}
+	}
+}